@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"lberg/gorender/internal"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func main() {
+	width := flag.Int("w", 512, "width of the image in pixels")
+	ratio := flag.Float64("ratio", 1, "ratio between width and height")
+	out := flag.String("o", "render.png", "output file, .png, .exr, .hdr, .pfm or .svg for line art, for animations .gif or a PNG pattern like frame%04d.png")
+	exposure := flag.Float64("exposure", 0, "exposure in stops")
+	toneMapping := flag.String("tonemap", "clamp", "tone mapping: clamp, reinhard, aces or hable")
+	backend := flag.String("backend", "raytrace", "how visible surfaces are found: raytrace or raster")
+	timeout := flag.Duration("timeout", 0, "stop after this time and save the last complete pass, 0 means no limit")
+	aov := flag.Bool("aov", false, "also save the depth, normal, id, position and where buffers, as layers in .exr files or images next to the output")
+	var anim animation
+	flag.Float64Var(&anim.duration, "turntable", 0, "render a turntable animation lasting this many seconds")
+	flag.Float64Var(&anim.fps, "fps", 24, "frames per second of animations")
+	flag.IntVar(&anim.loops, "loop", 0, "times a GIF animation plays, 0 means forever")
+	flag.Parse()
+
+	tm, err := internal.ParseToneMapping(*toneMapping)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	be, err := internal.ParseBackend(*backend)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	opts := []internal.RenderOption{internal.WithExposure(*exposure), internal.WithToneMapping(tm), internal.WithBackend(be)}
+	var aovs *internal.AOVs
+	if *aov {
+		aovs = &internal.AOVs{}
+		opts = append(opts, internal.WithAOVs(aovs, internal.AllAOVs))
+	}
+	if err := run(*width, *ratio, *out, *timeout, anim, aovs, opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// animation describes the turntable to render, if any
+type animation struct {
+	duration, fps float64
+	loops         int
+}
+
+func run(width int, ratio float64, out string, timeout time.Duration, anim animation, aovs *internal.AOVs, opts []internal.RenderOption) error {
+	engine := internal.NewEngine()
+	c1, err := internal.NewCube(2, 1, 3)
+	if err != nil {
+		return err
+	}
+	engine.Add(&c1)
+	eye := internal.Vector{X: -4, Y: -3, Z: 2}
+	engine.RepositionCamera(func(internal.Frame) internal.Frame {
+		return internal.LookAt(eye, internal.Zero, internal.K)
+	})
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if anim.duration > 0 {
+		engine.SetTimeline(&internal.Timeline{Camera: internal.Turntable(eye, internal.Zero, anim.duration)})
+		return animate(ctx, engine, anim, width, ratio, out, opts)
+	}
+
+	// last keeps the last complete pass, saved if the render runs out of time
+	var last *image.RGBA
+	start := time.Now()
+	opts = append(opts, internal.WithProgress(8, func(img *image.RGBA, pass, passes int) bool {
+		fmt.Fprintf(os.Stderr, "pass %d/%d done in %v\n", pass, passes, time.Since(start).Round(time.Millisecond))
+		last = image.NewRGBA(img.Bounds())
+		copy(last.Pix, img.Pix)
+		return true
+	}))
+
+	ext := strings.ToLower(filepath.Ext(out))
+	switch ext {
+	case ".svg":
+		return create(out, func(w io.Writer) error {
+			return engine.WriteSVG(w, width, ratio, internal.SVGOptions{
+				Hidden: &internal.StrokeStyle{Color: color.Gray{Y: 160}, Dash: []float64{4, 3}},
+			})
+		})
+	case ".exr", ".hdr", ".pfm":
+		// NOTE(@lberg): previews are tone mapped, there is no pass to fall back to
+		fb, err := engine.RenderHDR(ctx, width, ratio, opts...)
+		if err != nil {
+			return err
+		}
+		if ext == ".exr" {
+			return create(out, func(w io.Writer) error {
+				return internal.WriteEXR(w, internal.NewEXRImage(fb, aovs))
+			})
+		}
+		err = create(out, func(w io.Writer) error {
+			if ext == ".hdr" {
+				return internal.WriteRGBE(w, fb)
+			}
+			return internal.WritePFM(w, fb)
+		})
+		if err != nil {
+			return err
+		}
+		return saveAOVs(out, aovs)
+	}
+
+	img, err := engine.Render(ctx, width, ratio, opts...)
+	if errors.Is(err, context.DeadlineExceeded) && last != nil {
+		fmt.Fprintln(os.Stderr, "out of time, saving the last pass")
+		img, err = last, nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := savePNG(out, img); err != nil {
+		return err
+	}
+	return saveAOVs(out, aovs)
+}
+
+// saveAOVs saves the images of aovs next to out, render.png gets render.depth.png, render.normal.png, ...
+func saveAOVs(out string, aovs *internal.AOVs) error {
+	if aovs == nil {
+		return nil
+	}
+	base := strings.TrimSuffix(out, filepath.Ext(out))
+	for name, img := range aovs.Images() {
+		if err := savePNG(base+"."+name+".png", img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// animate renders the engine timeline to a GIF or, for any other output, to a PNG sequence
+func animate(ctx context.Context, engine *internal.Engine, anim animation,
+	width int, ratio float64, out string, opts []internal.RenderOption) error {
+	start := time.Now()
+	progress := func(idx int) {
+		fmt.Fprintf(os.Stderr, "frame %d done in %v\n", idx, time.Since(start).Round(time.Millisecond))
+	}
+	if strings.ToLower(filepath.Ext(out)) != ".gif" {
+		if !strings.Contains(out, "%") {
+			return fmt.Errorf("animations need a .gif output or a pattern like frame%%04d.png, not %q", out)
+		}
+		save := internal.PNGSequence(out)
+		return internal.RenderAnimation(ctx, engine, anim.fps, width, ratio, func(idx int, img *image.RGBA) error {
+			progress(idx)
+			return save(idx, img)
+		}, opts...)
+	}
+	ge := internal.NewGIFEncoder(anim.fps, anim.loops)
+	err := internal.RenderAnimation(ctx, engine, anim.fps, width, ratio, func(idx int, img *image.RGBA) error {
+		progress(idx)
+		return ge.Frame(idx, img)
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	return create(out, ge.Encode)
+}
+
+func savePNG(path string, img image.Image) error {
+	return create(path, func(w io.Writer) error {
+		return png.Encode(w, img)
+	})
+}
+
+// create writes the file at path with write, reporting errors on close as well
+func create(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := write(f); err != nil {
+		return err
+	}
+	return f.Close()
+}
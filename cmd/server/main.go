@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"lberg/gorender/internal"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on, local only by default, :8080 to share the streams on the LAN")
+	timeout := flag.Duration("timeout", 30*time.Second, "maximum duration of a render, 0 means no limit")
+	fps := flag.Float64("fps", 30, "highest frame rate of the /stream MJPEG streams, frames are only rendered when the scene changes")
+	quality := flag.Int("quality", 80, "JPEG quality of the streams, from 1 to 100")
+	backend := flag.String("backend", "raytrace", "how visible surfaces are found: raytrace or raster")
+	flag.Parse()
+
+	if !(*fps > 0) || *quality < 1 || *quality > 100 {
+		fmt.Fprintln(os.Stderr, "fps must be positive and quality between 1 and 100")
+		os.Exit(2)
+	}
+	be, err := internal.ParseBackend(*backend)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           newServer(*timeout, streamConfig{*fps, *quality}, internal.WithBackend(be)).handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+	}
+	fmt.Fprintf(os.Stderr, "listening on %s\n", *addr)
+	if err := srv.ListenAndServe(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
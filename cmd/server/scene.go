@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"lberg/gorender/internal"
+)
+
+// defaultColor is the color of quads and triangles without one, like the other types
+var defaultColor color.Color = color.RGBA{255, 0, 0, 255}
+
+// vec is a vector in JSON, [x, y, z]
+type vec [3]float64
+
+func (v vec) vector() internal.Vector {
+	return internal.Vector{X: v[0], Y: v[1], Z: v[2]}
+}
+
+// sceneJSON replaces the scene of a session
+type sceneJSON struct {
+	// Camera is left looking along I from the origin when nil
+	Camera   *cameraJSON  `json:"camera"`
+	Entities []entityJSON `json:"entities"`
+}
+
+// cameraJSON places the camera in eye looking at target, up is K when nil
+type cameraJSON struct {
+	Eye    vec  `json:"eye"`
+	Target vec  `json:"target"`
+	Up     *vec `json:"up"`
+}
+
+func (c *cameraJSON) frame() (internal.Frame, error) {
+	up := internal.K
+	if c.Up != nil {
+		up = c.Up.vector()
+	}
+	dir := c.Target.vector().Sub(c.Eye.vector())
+	if dir.Norm() == 0 || dir.Cross(up).Norm() == 0 {
+		return internal.Frame{}, fmt.Errorf("camera: eye, target and up must not be aligned")
+	}
+	return internal.LookAt(c.Eye.vector(), c.Target.vector(), up), nil
+}
+
+// entityJSON describes a renderable, which fields are used depends on its type:
+//   - cube: size, the width, height and depth
+//   - sphere: radius
+//   - cylinder: points, the centres of its 2 caps, and radius
+//   - quad: points, its 4 corners with the diagonal between the last 2
+//   - triangle: points, its 3 corners
+//
+// Spheres and cubes are centred on the origin, every renderable is then moved by position.
+type entityJSON struct {
+	Type     string  `json:"type"`
+	Size     vec     `json:"size"`
+	Radius   float64 `json:"radius"`
+	Points   []vec   `json:"points"`
+	Position vec     `json:"position"`
+	// Color is #rrggbb, the default color of the type when empty
+	Color string `json:"color"`
+}
+
+// add adds the entity to engine and returns its handle
+func (e *entityJSON) add(engine *internal.Engine) (internal.Handle, error) {
+	r, err := e.renderable()
+	if err != nil {
+		return "", err
+	}
+	var c color.Color
+	if e.Color != "" {
+		if c, err = parseColor(e.Color); err != nil {
+			return "", err
+		}
+	}
+	h := engine.Add(r)[0]
+	engine.Update(h, func(st *internal.EntityState) {
+		st.Place = st.Place.Move(e.Position.vector())
+		if c != nil {
+			st.Color = c
+		}
+	})
+	return h, nil
+}
+
+func (e *entityJSON) renderable() (internal.Renderable, error) {
+	points := func(n int) ([]internal.Vector, error) {
+		if len(e.Points) != n {
+			return nil, fmt.Errorf("%s: %d points expected, got %d", e.Type, n, len(e.Points))
+		}
+		ps := make([]internal.Vector, n)
+		for idx, p := range e.Points {
+			ps[idx] = p.vector()
+		}
+		return ps, nil
+	}
+	var r internal.Renderable
+	switch e.Type {
+	case "cube":
+		c, err := internal.NewCube(e.Size[0], e.Size[1], e.Size[2])
+		if err != nil {
+			return nil, err
+		}
+		r = &c
+	case "sphere":
+		s, err := internal.NewSphere(internal.Zero, e.Radius)
+		if err != nil {
+			return nil, err
+		}
+		r = &s
+	case "cylinder":
+		ps, err := points(2)
+		if err != nil {
+			return nil, err
+		}
+		c, err := internal.NewCylinder(ps[0], ps[1], e.Radius)
+		if err != nil {
+			return nil, err
+		}
+		r = &c
+	case "quad":
+		ps, err := points(4)
+		if err != nil {
+			return nil, err
+		}
+		q, err := internal.NewQuad(ps[0], ps[1], ps[2], ps[3], internal.WithQuadColor(defaultColor))
+		if err != nil {
+			return nil, err
+		}
+		r = &q
+	case "triangle":
+		ps, err := points(3)
+		if err != nil {
+			return nil, err
+		}
+		t, err := internal.NewTriangle(ps[0], ps[1], ps[2], internal.WithTriangleColor(defaultColor))
+		if err != nil {
+			return nil, err
+		}
+		r = &t
+	default:
+		return nil, fmt.Errorf("unknown entity type %q", e.Type)
+	}
+	return r, nil
+}
+
+// parseColor parses #rrggbb colors
+func parseColor(s string) (color.Color, error) {
+	var c color.RGBA
+	if n, err := fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B); err != nil || n != 3 || len(s) != 7 {
+		return nil, fmt.Errorf("invalid color %q, #rrggbb expected", s)
+	}
+	c.A = 255
+	return c, nil
+}
+
+// newEngine builds the engine of a scene, it returns the handles of the entities in order
+func (s *sceneJSON) newEngine() (*internal.Engine, []internal.Handle, error) {
+	engine := internal.NewEngine()
+	if s.Camera != nil {
+		f, err := s.Camera.frame()
+		if err != nil {
+			return nil, nil, err
+		}
+		engine.RepositionCamera(func(internal.Frame) internal.Frame { return f })
+	}
+	handles := make([]internal.Handle, len(s.Entities))
+	for idx := range s.Entities {
+		h, err := s.Entities[idx].add(engine)
+		if err != nil {
+			return nil, nil, fmt.Errorf("entity %d: %w", idx, err)
+		}
+		handles[idx] = h
+	}
+	return engine, handles, nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"lberg/gorender/internal"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxWidth bounds the size of renders, a 4096x4096 image is already 64 MB
+const maxWidth = 4096
+
+// server keeps an engine per session. The session is given by the session query
+// parameter or the X-Session header, it is "default" when both are missing.
+type server struct {
+	lock     sync.Mutex
+	sessions map[string]*internal.Engine
+	// timeout bounds each render, the render is cancelled as well when the client goes away
+	timeout time.Duration
+	opts    []internal.RenderOption
+	// streams are the running streams, by session and size
+	streams map[streamKey]*stream
+	stream  streamConfig
+}
+
+func newServer(timeout time.Duration, sc streamConfig, opts ...internal.RenderOption) *server {
+	return &server{
+		sessions: make(map[string]*internal.Engine),
+		timeout:  timeout,
+		opts:     opts,
+		streams:  make(map[streamKey]*stream),
+		stream:   sc,
+	}
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /scene", s.putScene)
+	mux.HandleFunc("DELETE /scene", s.deleteScene)
+	mux.HandleFunc("POST /entities", s.postEntity)
+	mux.HandleFunc("DELETE /entities/{handle}", s.deleteEntity)
+	mux.HandleFunc("PUT /camera", s.putCamera)
+	mux.HandleFunc("GET /render", s.render)
+	mux.HandleFunc("GET /stream", s.streamView)
+	return mux
+}
+
+func sessionID(r *http.Request) string {
+	if id := r.URL.Query().Get("session"); id != "" {
+		return id
+	}
+	if id := r.Header.Get("X-Session"); id != "" {
+		return id
+	}
+	return "default"
+}
+
+// engine returns the engine of the session of r, reporting an error to w if there is none
+func (s *server) engine(w http.ResponseWriter, r *http.Request) (*internal.Engine, bool) {
+	id := sessionID(r)
+	s.lock.Lock()
+	engine, ok := s.sessions[id]
+	s.lock.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, unknownSession(id))
+	}
+	return engine, ok
+}
+
+func unknownSession(id string) error {
+	return fmt.Errorf("unknown session %q, PUT a scene first", id)
+}
+
+// putScene creates or replaces the scene of the session,
+// renders in progress finish with the previous one, streams switch to the new one
+func (s *server) putScene(w http.ResponseWriter, r *http.Request) {
+	var scene sceneJSON
+	if !readJSON(w, r, &scene) {
+		return
+	}
+	engine, handles, err := scene.newEngine()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.lock.Lock()
+	s.sessions[sessionID(r)] = engine
+	s.replaceEngine(sessionID(r), engine)
+	s.lock.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"handles": handles})
+}
+
+// deleteScene removes the session, ending its streams
+func (s *server) deleteScene(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	delete(s.sessions, sessionID(r))
+	s.replaceEngine(sessionID(r), nil)
+	s.lock.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) postEntity(w http.ResponseWriter, r *http.Request) {
+	engine, ok := s.engine(w, r)
+	if !ok {
+		return
+	}
+	var e entityJSON
+	if !readJSON(w, r, &e) {
+		return
+	}
+	h, err := e.add(engine)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"handle": h})
+}
+
+func (s *server) deleteEntity(w http.ResponseWriter, r *http.Request) {
+	engine, ok := s.engine(w, r)
+	if !ok {
+		return
+	}
+	h := internal.Handle(r.PathValue("handle"))
+	if _, ok := engine.Inspect(h); !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown entity %q", h))
+		return
+	}
+	engine.Remove(h)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) putCamera(w http.ResponseWriter, r *http.Request) {
+	engine, ok := s.engine(w, r)
+	if !ok {
+		return
+	}
+	var c cameraJSON
+	if !readJSON(w, r, &c) {
+		return
+	}
+	f, err := c.frame()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	engine.RepositionCamera(func(internal.Frame) internal.Frame { return f })
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// render answers GET /render?w=&ratio= with a PNG, w defaults to 512 and ratio to 1
+func (s *server) render(w http.ResponseWriter, r *http.Request) {
+	engine, ok := s.engine(w, r)
+	if !ok {
+		return
+	}
+	width, ratio, err := renderSize(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx := r.Context()
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	img, err := engine.Render(ctx, width, ratio, s.opts...)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("render took more than %v", s.timeout))
+		return
+	case r.Context().Err() != nil:
+		// NOTE(@lberg): the client is gone, there is nobody to answer
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// NOTE(@lberg): encoded before answering, so a failure can still be reported
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(buf.Bytes())
+}
+
+// renderSize parses the w and ratio query parameters
+func renderSize(r *http.Request) (int, float64, error) {
+	q := r.URL.Query()
+	width, ratio := 512, 1.
+	if v := q.Get("w"); v != "" {
+		var err error
+		if width, err = strconv.Atoi(v); err != nil || width < 1 || width > maxWidth {
+			return 0, 0, fmt.Errorf("w must be an integer between 1 and %d", maxWidth)
+		}
+	}
+	if v := q.Get("ratio"); v != "" {
+		var err error
+		if ratio, err = strconv.ParseFloat(v, 64); err != nil || !(ratio > 0) {
+			return 0, 0, fmt.Errorf("ratio must be a positive number")
+		}
+	}
+	if h := float64(width) / ratio; h < 1 || h > maxWidth {
+		return 0, 0, fmt.Errorf("the height w/ratio must be between 1 and %d", maxWidth)
+	}
+	return width, ratio, nil
+}
+
+// readJSON decodes the body of r in v, reporting errors to w
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// do sends a request with the given JSON body to h and returns the response
+func do(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer(t *testing.T) {
+	h := newServer(10*time.Second, streamConfig{fps: 30, quality: 80}).handler()
+
+	// nothing works before the scene is created
+	rec := do(h, "GET", "/render?w=20", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), `"error"`)
+
+	// a quad without color in front of the camera fills the view
+	rec = do(h, "PUT", "/scene", `{
+		"camera": {"eye": [-5, 0, 0], "target": [0, 0, 0]},
+		"entities": [{"type": "quad", "points": [[0, -20, -20], [0, 20, -20], [0, -20, 20], [0, 20, 20]]}]
+	}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var scene struct{ Handles []string }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &scene))
+	require.Len(t, scene.Handles, 1)
+
+	rec = do(h, "GET", "/render?w=20&ratio=2", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	img, err := png.Decode(rec.Body)
+	require.NoError(t, err)
+	require.Equal(t, 20, img.Bounds().Dx())
+	require.Equal(t, 10, img.Bounds().Dy())
+	require.Equal(t, color.RGBAModel.Convert(defaultColor), color.RGBAModel.Convert(img.At(10, 5)))
+
+	// entities are added and removed by handle, triangles get a color as well
+	rec = do(h, "POST", "/entities", `{"type": "triangle", "points": [[-1, 0, 0], [-1, 1, 0], [-1, 0, 1]]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var added struct{ Handle string }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &added))
+	rec = do(h, "POST", "/entities", `{"type": "sphere", "radius": 1, "color": "#00ff00", "position": [-2, 0, 0]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = do(h, "GET", "/render?w=20", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	img, err = png.Decode(rec.Body)
+	require.NoError(t, err)
+	require.Equal(t, color.RGBA{0, 255, 0, 255}, color.RGBAModel.Convert(img.At(10, 10)))
+
+	require.Equal(t, http.StatusNoContent, do(h, "DELETE", "/entities/"+added.Handle, "").Code)
+	require.Equal(t, http.StatusNotFound, do(h, "DELETE", "/entities/"+added.Handle, "").Code)
+
+	// invalid entities are rejected
+	for _, body := range []string{
+		`{"type": "triangle", "points": [[0, 0, 0], [1, 0, 0]]}`,
+		`{"type": "cone"}`,
+		`{"type": "sphere", "radius": 1, "color": "green"}`,
+		`{"type": "sphere", "colour": "#00ff00"}`,
+		`not json`,
+	} {
+		require.Equal(t, http.StatusBadRequest, do(h, "POST", "/entities", body).Code, body)
+	}
+
+	// the camera moves, looking from behind the quad shows the background
+	require.Equal(t, http.StatusNoContent, do(h, "PUT", "/camera", `{"eye": [5, 0, 0], "target": [10, 0, 0]}`).Code)
+	rec = do(h, "GET", "/render?w=20", "")
+	img, err = png.Decode(rec.Body)
+	require.NoError(t, err)
+	_, _, _, a := img.At(10, 10).RGBA()
+	require.Zero(t, a)
+	require.Equal(t, http.StatusBadRequest, do(h, "PUT", "/camera", `{"eye": [0, 0, 5], "target": [0, 0, 0]}`).Code)
+
+	// sizes are checked
+	for _, q := range []string{"w=0", "w=abc", "w=5000", "ratio=0", "ratio=-1", "w=100&ratio=0.01"} {
+		require.Equal(t, http.StatusBadRequest, do(h, "GET", "/render?"+q, "").Code, q)
+	}
+
+	// sessions are independent
+	require.Equal(t, http.StatusNotFound, do(h, "GET", "/render?w=20&session=other", "").Code)
+	rec = do(h, "PUT", "/scene?session=other", `{"entities": []}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, http.StatusOK, do(h, "GET", "/render?w=20&session=other", "").Code)
+	require.Equal(t, http.StatusNoContent, do(h, "DELETE", "/scene?session=other", "").Code)
+	require.Equal(t, http.StatusNotFound, do(h, "GET", "/render?w=20&session=other", "").Code)
+	require.Equal(t, http.StatusOK, do(h, "GET", "/render?w=20", "").Code)
+}
+
+func TestServerTimeout(t *testing.T) {
+	h := newServer(time.Nanosecond, streamConfig{fps: 30, quality: 80}).handler()
+	require.Equal(t, http.StatusOK, do(h, "PUT", "/scene", `{"entities": [{"type": "cube", "size": [1, 1, 1]}]}`).Code)
+	rec := do(h, "GET", "/render?w=200", "")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code, rec.Body.String())
+}
+
+func TestStream(t *testing.T) {
+	srv := newServer(10*time.Second, streamConfig{fps: 100, quality: 90})
+	ts := httptest.NewServer(srv.handler())
+	defer ts.Close()
+	put := func(col string) {
+		req, err := http.NewRequest("PUT", ts.URL+"/scene", strings.NewReader(`{
+			"camera": {"eye": [-5, 0, 0], "target": [0, 0, 0]},
+			"entities": [{"type": "cube", "size": [20, 20, 2], "color": "`+col+`"}]
+		}`))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	resp, err := http.Get(ts.URL + "/stream?w=16")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	put("#ff0000")
+	// viewers share the stream and see the frames of the new scenes
+	var parts []*multipart.Reader
+	for range 2 {
+		resp, err := http.Get(ts.URL + "/stream?w=16")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		require.Equal(t, "multipart/x-mixed-replace", mediaType)
+		parts = append(parts, multipart.NewReader(resp.Body, params["boundary"]))
+	}
+	srv.lock.Lock()
+	require.Len(t, srv.streams, 1)
+	srv.lock.Unlock()
+	center := func(mr *multipart.Reader) color.Color {
+		part, err := mr.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, "image/jpeg", part.Header.Get("Content-Type"))
+		img, err := jpeg.Decode(part)
+		require.NoError(t, err)
+		return img.At(8, 8)
+	}
+	red := func(c color.Color) bool {
+		r, g, _, _ := c.RGBA()
+		return r > 0xc000 && g < 0x4000
+	}
+	for _, mr := range parts {
+		require.True(t, red(center(mr)))
+	}
+	put("#00ff00")
+	for _, mr := range parts {
+		require.False(t, red(center(mr)))
+	}
+
+	// deleting the scene ends the streams
+	req, err := http.NewRequest("DELETE", ts.URL+"/scene", nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	for _, mr := range parts {
+		_, err := mr.NextPart()
+		require.Error(t, err)
+	}
+}
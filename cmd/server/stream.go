@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"lberg/gorender/internal"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// streamConfig applies to all the streams of a server
+type streamConfig struct {
+	// fps is the highest frame rate, frames are only rendered when the scene changes
+	fps     float64
+	quality int
+}
+
+// streamKey identifies the streams viewers share
+type streamKey struct {
+	session string
+	width   int
+	ratio   float64
+}
+
+// stream renders the view of an engine as JPEG frames for all its viewers.
+// Viewers always get the latest frame, slow ones skip the frames they missed.
+type stream struct {
+	cancel context.CancelFunc
+	done   <-chan struct{}
+
+	lock   sync.Mutex
+	engine *internal.Engine
+	// replaced is signalled when the engine is replaced, the view has to be rendered again
+	replaced chan struct{}
+	frame    []byte
+	// seq numbers the frames, 0 before the first one
+	seq uint64
+	// ready is closed and replaced at each new frame
+	ready   chan struct{}
+	viewers int
+}
+
+// join returns the stream of the session with the given size, started if needed,
+// false if the session does not exist. Viewers must leave it when they are done.
+func (s *server) join(session string, width int, ratio float64) (*stream, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	// NOTE(@lberg): the engine is looked up under the lock, a scene put
+	// in the meantime would not switch a stream which does not exist yet
+	engine, ok := s.sessions[session]
+	if !ok {
+		return nil, false
+	}
+	key := streamKey{session, width, ratio}
+	st, ok := s.streams[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		st = &stream{
+			cancel:   cancel,
+			done:     ctx.Done(),
+			engine:   engine,
+			replaced: make(chan struct{}, 1),
+			ready:    make(chan struct{}),
+		}
+		s.streams[key] = st
+		go s.run(ctx, st, width, ratio)
+	}
+	st.viewers++
+	return st, true
+}
+
+// leave stops the stream once its last viewer is gone
+func (s *server) leave(st *stream) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if st.viewers--; st.viewers > 0 {
+		return
+	}
+	st.cancel()
+	for key, other := range s.streams {
+		if other == st {
+			delete(s.streams, key)
+		}
+	}
+}
+
+// replaceEngine switches the streams of session to engine, or ends them if engine is nil.
+// The server lock must be held.
+func (s *server) replaceEngine(session string, engine *internal.Engine) {
+	for key, st := range s.streams {
+		if key.session != session {
+			continue
+		}
+		if engine == nil {
+			st.cancel()
+			delete(s.streams, key)
+			continue
+		}
+		st.lock.Lock()
+		st.engine = engine
+		st.lock.Unlock()
+		select {
+		case st.replaced <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run renders a frame each time the scene changes, at most fps times a second
+func (s *server) run(ctx context.Context, st *stream, width int, ratio float64) {
+	interval := time.Duration(float64(time.Second) / s.stream.fps)
+	var last time.Time
+	for {
+		st.lock.Lock()
+		engine := st.engine
+		st.lock.Unlock()
+		// NOTE(@lberg): the channel is taken before rendering, so changes
+		// made during the render are not missed
+		_, changed := engine.Changed()
+		if err := s.renderFrame(ctx, st, engine, width, ratio); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "stream %dx%v: %v\n", width, ratio, err)
+		}
+		last = time.Now()
+
+		select {
+		case <-changed:
+		case <-st.replaced:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case <-time.After(time.Until(last.Add(interval))):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// renderFrame renders the view of engine and publishes it to the viewers of st
+func (s *server) renderFrame(ctx context.Context, st *stream, engine *internal.Engine, width int, ratio float64) error {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	img, err := engine.Render(ctx, width, ratio, s.opts...)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: s.stream.quality}); err != nil {
+		return err
+	}
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.frame = buf.Bytes()
+	st.seq++
+	close(st.ready)
+	st.ready = make(chan struct{})
+	return nil
+}
+
+// next returns the latest frame once it is past seq, with its number.
+// It returns an error once ctx is done or the stream is over.
+func (st *stream) next(ctx context.Context, seq uint64) ([]byte, uint64, error) {
+	for {
+		st.lock.Lock()
+		frame, cur, ready := st.frame, st.seq, st.ready
+		st.lock.Unlock()
+		if cur > seq {
+			return frame, cur, nil
+		}
+		select {
+		case <-ready:
+		case <-st.done:
+			return nil, 0, fmt.Errorf("stream ended")
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+}
+
+// streamView answers GET /stream?w=&ratio= with an MJPEG stream of the session view,
+// which browsers and OBS show as a live video. Viewers of the same session
+// and size share the frames.
+func (s *server) streamView(w http.ResponseWriter, r *http.Request) {
+	width, ratio, err := renderSize(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	st, ok := s.join(sessionID(r), width, ratio)
+	if !ok {
+		writeError(w, http.StatusNotFound, unknownSession(sessionID(r)))
+		return
+	}
+	defer s.leave(st)
+
+	const boundary = "frame"
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+boundary)
+	w.Header().Set("Cache-Control", "no-store")
+	rc := http.NewResponseController(w)
+	var seq uint64
+	for {
+		var frame []byte
+		frame, seq, err = st.next(r.Context(), seq)
+		if err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", boundary, len(frame))
+		if err == nil {
+			_, err = w.Write(frame)
+		}
+		if err == nil {
+			_, err = io.WriteString(w, "\r\n")
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
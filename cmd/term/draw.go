@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+type drawMode int
+
+const (
+	// truecolorMode draws two pixels per cell with the upper half block,
+	// the upper pixel in the foreground color and the lower one in the background
+	truecolorMode drawMode = iota
+	// asciiMode draws the luminance of the pixels with characters, for terminals without colors
+	asciiMode
+)
+
+func (m drawMode) String() string {
+	switch m {
+	case asciiMode:
+		return "ascii"
+	default:
+		return "truecolor"
+	}
+}
+
+func parseDrawMode(s string) (drawMode, error) {
+	for _, m := range []drawMode{truecolorMode, asciiMode} {
+		if m.String() == s {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown output mode %q", s)
+}
+
+// ramp goes from dark to bright, on a dark terminal background
+const ramp = " .:-=+*#%@"
+
+// draw writes img to b, each cell covering two rows of pixels, from the top left of the terminal
+func draw(b *strings.Builder, img *image.RGBA, mode drawMode) {
+	bounds := img.Bounds()
+	b.WriteString("\x1b[H")
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 2 {
+		// NOTE(@lberg): colors are only sent when they change, it divides
+		// the output by about ten on flat surfaces
+		var fg, bg color.RGBA
+		fresh := true
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			top := img.RGBAAt(x, y)
+			bottom := top
+			if y+1 < bounds.Max.Y {
+				bottom = img.RGBAAt(x, y+1)
+			}
+			if mode == asciiMode {
+				l := (luminance(top) + luminance(bottom)) / 2
+				b.WriteByte(ramp[min(int(l*float64(len(ramp))), len(ramp)-1)])
+				continue
+			}
+			if fresh || top != fg {
+				fmt.Fprintf(b, "\x1b[38;2;%d;%d;%dm", top.R, top.G, top.B)
+			}
+			if fresh || bottom != bg {
+				fmt.Fprintf(b, "\x1b[48;2;%d;%d;%dm", bottom.R, bottom.G, bottom.B)
+			}
+			fg, bg, fresh = top, bottom, false
+			b.WriteString("▀")
+		}
+		b.WriteString("\x1b[0m\x1b[K\r\n")
+	}
+}
+
+// luminance of c in [0, 1], from its sRGB components
+func luminance(c color.RGBA) float64 {
+	return (0.2126*float64(c.R) + 0.7152*float64(c.G) + 0.0722*float64(c.B)) / 255
+}
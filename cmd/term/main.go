@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"lberg/gorender/internal"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	output := flag.String("mode", "truecolor", "output: truecolor half blocks, or ascii characters for terminals without colors")
+	backend := flag.String("backend", "raytrace", "how visible surfaces are found: raytrace or raster")
+	flag.Parse()
+
+	mode, err := parseDrawMode(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	be, err := internal.ParseBackend(*backend)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := run(mode, []internal.RenderOption{internal.WithBackend(be)}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(mode drawMode, opts []internal.RenderOption) error {
+	engine := internal.NewEngine()
+	c1, err := internal.NewCube(2, 1, 3)
+	if err != nil {
+		return err
+	}
+	engine.Add(&c1)
+	nav := internal.NewNavigator(internal.I.Mul(-5), engine.Bounds())
+
+	restore, err := makeRaw(os.Stdin)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	// NOTE(@lberg): the alternate screen keeps the shell history out of the way
+	// and gives it back as it was on exit
+	os.Stdout.WriteString("\x1b[?1049h\x1b[?25l")
+	defer os.Stdout.WriteString("\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan string, 64)
+	go readKeys(os.Stdin, keys)
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+
+	var b strings.Builder
+	for {
+		cols, rows, err := size(os.Stdout)
+		if err != nil {
+			return err
+		}
+		// the last row is the status line, each other row has two pixels
+		height := 2 * max(rows-1, 1)
+		cols = max(cols, 1)
+		engine.RepositionCamera(nav.Reposition)
+		img, err := engine.Render(context.Background(), cols, float64(cols)/float64(height), opts...)
+		if err != nil {
+			return err
+		}
+		b.Reset()
+		draw(&b, img, mode)
+		other := internal.FlyNav
+		if nav.Mode == internal.FlyNav {
+			other = internal.OrbitNav
+		}
+		status := fmt.Sprintf(" %v | %v | WASDQE move, arrows orbit, +/- zoom, F %v, M output, Esc quit", nav.Mode, mode, other)
+		b.WriteString("\x1b[7m" + status[:min(len(status), cols)] + "\x1b[0m\x1b[K")
+		if _, err := os.Stdout.WriteString(b.String()); err != nil {
+			return err
+		}
+
+		select {
+		case k, ok := <-keys:
+			for {
+				switch {
+				case !ok || k == keyEsc || k == keyCtrlC:
+					return nil
+				case k == "m" || k == "M":
+					mode = asciiMode - mode
+				default:
+					navigate(nav, k)
+				}
+				// apply the pending keys at once, rendering is slower than key repeat
+				select {
+				case k, ok = <-keys:
+					continue
+				default:
+				}
+				break
+			}
+		case <-resized:
+		}
+	}
+}
+
+const (
+	keyUp    = "up"
+	keyDown  = "down"
+	keyLeft  = "left"
+	keyRight = "right"
+	keyEsc   = "esc"
+	keyCtrlC = "ctrl+c"
+)
+
+// escTimeout tells the Esc key apart from the start of an escape sequence,
+// the bytes of a sequence come together but not always in the same read
+const escTimeout = 50 * time.Millisecond
+
+// readKeys sends the keys read from r to keys, until r fails
+func readKeys(r io.Reader, keys chan<- string) {
+	chunks := make(chan []byte)
+	go func() {
+		for {
+			buf := make([]byte, 64)
+			n, err := r.Read(buf)
+			if err != nil {
+				close(chunks)
+				return
+			}
+			chunks <- buf[:n]
+		}
+	}()
+	var pending []byte
+	for {
+		// NOTE(@lberg): an incomplete escape sequence waits for the rest of it,
+		// once nothing comes it is the Esc key followed by other keys
+		var timeout <-chan time.Time
+		if len(pending) > 0 {
+			timeout = time.After(escTimeout)
+		}
+		flush := false
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				close(keys)
+				return
+			}
+			pending = append(pending, chunk...)
+		case <-timeout:
+			flush = true
+		}
+		pending = parseKeys(pending, flush, func(k string) { keys <- k })
+	}
+}
+
+// parseKeys sends the keys of in to emit. An incomplete escape sequence at the end
+// is returned to be completed by the next read, unless flush is set.
+func parseKeys(in []byte, flush bool, emit func(string)) []byte {
+	arrows := map[byte]string{'A': keyUp, 'B': keyDown, 'C': keyRight, 'D': keyLeft}
+	for len(in) > 0 {
+		if in[0] != 0x1b {
+			if in[0] == 0x03 {
+				emit(keyCtrlC)
+			} else {
+				emit(string(in[:1]))
+			}
+			in = in[1:]
+			continue
+		}
+		n := escapeLen(in)
+		switch {
+		case n == 0 && !flush:
+			return in
+		case n <= 1:
+			emit(keyEsc)
+			n = 1
+		case n == 3:
+			// other sequences, like function keys, are ignored
+			if k, ok := arrows[in[2]]; ok {
+				emit(k)
+			}
+		}
+		in = in[n:]
+	}
+	return nil
+}
+
+// escapeLen returns the length of the escape sequence in starts with, 0 if it is incomplete
+// and 1 for an escape which does not start a sequence
+func escapeLen(in []byte) int {
+	switch {
+	case len(in) < 2:
+		return 0
+	case in[1] == 'O':
+		if len(in) < 3 {
+			return 0
+		}
+		return 3
+	case in[1] == '[':
+		// parameter and intermediate bytes, up to the final one
+		for idx := 2; idx < len(in); idx++ {
+			if in[idx] < 0x20 || in[idx] > 0x3f {
+				return idx + 1
+			}
+		}
+		return 0
+	}
+	return 1
+}
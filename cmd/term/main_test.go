@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeys(t *testing.T) {
+	var keys []string
+	parse := func(in string, flush bool) string {
+		keys = nil
+		return string(parseKeys([]byte(in), flush, func(k string) { keys = append(keys, k) }))
+	}
+
+	require.Empty(t, parse("w\x1b[AM\x03", false))
+	require.Equal(t, []string{"w", keyUp, "M", keyCtrlC}, keys)
+
+	// sequences split across reads wait for their end
+	rest := parse("a\x1b", false)
+	require.Equal(t, "\x1b", rest)
+	require.Equal(t, []string{"a"}, keys)
+	require.Empty(t, parse(rest+"OB", false))
+	require.Equal(t, []string{keyDown}, keys)
+	rest = parse("\x1b[1;", false)
+	require.Equal(t, "\x1b[1;", rest)
+	require.Empty(t, parse(rest+"5Cd", false))
+	require.Equal(t, []string{"d"}, keys)
+
+	// an escape not followed by a sequence is the Esc key
+	require.Empty(t, parse("\x1b", true))
+	require.Equal(t, []string{keyEsc}, keys)
+	require.Empty(t, parse("\x1bm", false))
+	require.Equal(t, []string{keyEsc, "m"}, keys)
+	require.Empty(t, parse("\x1b[", true))
+	require.Equal(t, []string{keyEsc, "["}, keys)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"lberg/gorender/internal"
+	"time"
+	"unicode"
+)
+
+// keyStep is how long each key press moves the camera, as if the key was held that long
+const keyStep = 100 * time.Millisecond
+
+// navigate applies a key press to n with the bindings of cmd/ui.
+// Terminals do not report released keys, so each press moves the camera by a step
+// and repeated presses keep it moving.
+//   - W/S forward and backward (dolly in orbit mode)
+//   - A/D left and right, Q/E down and up, faster with shift
+//   - F toggles between orbit and fly
+//   - the arrows orbit (look around in fly mode), like a left drag
+//   - +/- zoom (dolly in fly mode), like the wheel
+//
+// It returns false if the key is not a navigation one.
+func navigate(n *internal.Navigator, k string) bool {
+	switch k {
+	case "f", "F":
+		n.Toggle()
+		return true
+	case keyUp, keyDown, keyLeft, keyRight:
+		// like dragging the view by a sixteenth of its width in the direction of the arrow
+		var dx, dy float64
+		switch k {
+		case keyUp:
+			dy = -1
+		case keyDown:
+			dy = 1
+		case keyLeft:
+			dx = -1
+		case keyRight:
+			dx = 1
+		}
+		n.Drag(internal.NavOrbitDrag, dx, dy, 16)
+		return true
+	case "+", "=":
+		n.Scroll(-100)
+		return true
+	case "-":
+		n.Scroll(100)
+		return true
+	}
+	r := []rune(k)
+	if len(r) != 1 {
+		return false
+	}
+	m, ok := internal.NavMoveKey(r[0])
+	if !ok {
+		return false
+	}
+	n.Step(m, keyStep, unicode.IsUpper(r[0]))
+	return true
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errUnsupported = errors.New("terminal control is not supported on this platform")
+
+func makeRaw(*os.File) (func() error, error) {
+	return nil, errUnsupported
+}
+
+func size(*os.File) (int, int, error) {
+	return 0, 0, errUnsupported
+}
+
+func notifyResize(chan<- os.Signal) {}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"lberg/gorender/internal"
+	"strconv"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+const panelWidth = unit.Dp(240)
+
+// inspector is the side panel showing the selected entity,
+// edits to its fields are applied to the engine as they are typed
+type inspector struct {
+	engine *internal.Engine
+	// info is the state of the selected entity, empty ID when nothing is selected
+	info    internal.EntityInfo
+	x, y, z widget.Editor
+	color   widget.Editor
+}
+
+func newInspector(engine *internal.Engine) *inspector {
+	in := &inspector{engine: engine}
+	for _, ed := range in.editors() {
+		ed.SingleLine = true
+	}
+	return in
+}
+
+func (in *inspector) editors() []*widget.Editor {
+	return []*widget.Editor{&in.x, &in.y, &in.z, &in.color}
+}
+
+// selectID selects the entity with the given handle, an empty handle clears the selection
+func (in *inspector) selectID(h internal.Handle) {
+	in.engine.Select(h)
+	info, ok := in.engine.Inspect(h)
+	if !ok {
+		in.info = internal.EntityInfo{}
+		return
+	}
+	in.info = info
+	in.x.SetText(formatFloat(info.Position.X))
+	in.y.SetText(formatFloat(info.Position.Y))
+	in.z.SetText(formatFloat(info.Position.Z))
+	in.color.SetText(formatColor(info.Color))
+}
+
+// editing is true while one of the fields has the keyboard focus
+func (in *inspector) editing(gtx layout.Context) bool {
+	for _, ed := range in.editors() {
+		if gtx.Focused(ed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (in *inspector) update(gtx layout.Context) {
+	changed := func(ed *widget.Editor) bool {
+		changed := false
+		for {
+			ev, ok := ed.Update(gtx)
+			if !ok {
+				return changed
+			}
+			if _, ok := ev.(widget.ChangeEvent); ok {
+				changed = true
+			}
+		}
+	}
+	cx, cy, cz := changed(&in.x), changed(&in.y), changed(&in.z)
+	if cx || cy || cz {
+		in.applyPosition()
+	}
+	if changed(&in.color) {
+		in.applyColor()
+	}
+}
+
+func (in *inspector) applyPosition() {
+	if in.x.Text() == formatFloat(in.info.Position.X) && in.y.Text() == formatFloat(in.info.Position.Y) &&
+		in.z.Text() == formatFloat(in.info.Position.Z) {
+		// NOTE(@lberg): fields were just filled from the entity, applying them
+		// would move it by the rounding error
+		return
+	}
+	var coords [3]float64
+	for idx, ed := range []*widget.Editor{&in.x, &in.y, &in.z} {
+		v, err := strconv.ParseFloat(strings.TrimSpace(ed.Text()), 64)
+		if err != nil {
+			// NOTE(@lberg): likely half typed, wait for a valid value
+			return
+		}
+		coords[idx] = v
+	}
+	pos := internal.Vector{X: coords[0], Y: coords[1], Z: coords[2]}
+	delta := pos.Sub(in.info.Position)
+	if in.engine.PlaceWorld(in.info.ID, func(f internal.Frame) internal.Frame { return f.Move(delta) }) {
+		in.info.Position = pos
+	}
+}
+
+func (in *inspector) applyColor() {
+	c, ok := parseColor(in.color.Text())
+	if !ok || in.color.Text() == formatColor(in.info.Color) {
+		return
+	}
+	if in.engine.Recolor(in.info.ID, c) {
+		in.info.Color = c
+	}
+}
+
+func (in *inspector) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	if in.info.ID == "" {
+		return layout.Dimensions{}
+	}
+	in.update(gtx)
+
+	gtx.Constraints.Max.X = gtx.Dp(panelWidth)
+	gtx.Constraints.Min = gtx.Constraints.Max
+	return layout.Stack{}.Layout(gtx,
+		layout.Expanded(func(gtx layout.Context) layout.Dimensions {
+			paint.FillShape(gtx.Ops, th.Bg, clip.Rect{Max: gtx.Constraints.Min}.Op())
+			return layout.Dimensions{Size: gtx.Constraints.Min}
+		}),
+		layout.Stacked(func(gtx layout.Context) layout.Dimensions {
+			return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return in.layoutFields(gtx, th)
+			})
+		}),
+	)
+}
+
+func (in *inspector) layoutFields(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	title := func(txt string) layout.FlexChild {
+		return layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, material.Subtitle2(th, txt).Layout)
+		})
+	}
+	text := func(txt string) layout.FlexChild {
+		return layout.Rigid(material.Body2(th, txt).Layout)
+	}
+	field := func(ed *widget.Editor, label string) layout.FlexChild {
+		return layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Alignment: layout.Baseline}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min.X = gtx.Dp(unit.Dp(24))
+					return material.Body2(th, label).Layout(gtx)
+				}),
+				layout.Flexed(1, material.Editor(th, ed, label).Layout),
+			)
+		})
+	}
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		title("ID"),
+		text(string(in.info.ID)),
+		title("Type"),
+		text(in.info.Type),
+		title("Position"),
+		field(&in.x, "X"),
+		field(&in.y, "Y"),
+		field(&in.z, "Z"),
+		title("Color"),
+		field(&in.color, "#"),
+	)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 3, 64)
+}
+
+func formatColor(c color.Color) string {
+	if c == nil {
+		return ""
+	}
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
+}
+
+// parseColor reads colors in the #rrggbb form, the # is optional
+func parseColor(s string) (color.Color, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) != 6 {
+		return nil, false
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil, false
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, true
+}
@@ -1,16 +1,17 @@
 package main
 
 import (
-	"image"
 	"lberg/gorender/internal"
+	"math"
 	"os"
-	"time"
 
 	"gioui.org/app"
-	"gioui.org/io/key"
+	"gioui.org/f32"
+	"gioui.org/font/gofont"
+	"gioui.org/layout"
 	"gioui.org/op"
-	"gioui.org/op/paint"
-	"gioui.org/widget"
+	"gioui.org/text"
+	"gioui.org/widget/material"
 )
 
 func main() {
@@ -20,10 +21,8 @@ func main() {
 		panic(err)
 	}
 
-	engine.Add(&c1)
-	engine.RepositionCamera(func(f internal.Frame) internal.Frame {
-		return f.Move(internal.I.Mul(-5))
-	})
+	h := engine.Add(&c1)[0]
+	engine.SetTimeline(spin(h, 8))
 
 	go func() {
 		w := new(app.Window)
@@ -41,18 +40,19 @@ func main() {
 
 func run(w *app.Window, engine *internal.Engine) error {
 	var ops op.Ops
-	img := image.NewRGBA(image.Rect(0, 0, 0, 0))
+	vp := newViewport(engine)
+	nav := newNavigator(internal.I.Mul(-5), engine.Bounds())
+	engine.RepositionCamera(nav.Reposition)
+	insp := newInspector(engine)
+	play := newPlayer(engine)
+	nav.onClick = func(pos f32.Point) {
+		insp.selectID(vp.pick(pos))
+	}
+	th := material.NewTheme()
+	th.Shaper = text.NewShaper(text.WithCollection(gofont.Collection()))
 
-	go func() {
-		for {
-			for range time.Tick(time.Millisecond * 30) {
-				img = engine.Render(512, 1)
-				w.Invalidate()
-			}
-		}
-	}()
+	go vp.run(w)
 
-	imageWidget := widget.Image{Src: paint.NewImageOp(img)}
 	for {
 		switch e := w.Event().(type) {
 		case app.DestroyEvent:
@@ -60,41 +60,43 @@ func run(w *app.Window, engine *internal.Engine) error {
 		case app.FrameEvent:
 			gtx := app.NewContext(&ops, e)
 
-			for {
-				ev, ok := gtx.Event(
-					key.Filter{Name: "W"},
-					key.Filter{Name: "A"},
-					key.Filter{Name: "S"},
-					key.Filter{Name: "D"},
-				)
-				if !ok {
-					break
-				}
-				keyEv, ok := ev.(key.Event)
-				if !ok {
-					break
-				}
-				if keyEv.State == key.Press {
-					engine.RepositionCamera(func(f internal.Frame) internal.Frame {
-						rot := internal.DegToRad(1)
-						switch keyEv.Name {
-						case "W":
-							f = f.Rotate(internal.NewLine(internal.Zero, f.J), rot)
-						case "A":
-							f = f.Rotate(internal.NewLine(internal.Zero, internal.K), -rot)
-						case "S":
-							f = f.Rotate(internal.NewLine(internal.Zero, f.J), -rot)
-						case "D":
-							f = f.Rotate(internal.NewLine(internal.Zero, internal.K), rot)
-						}
-						return f
-					})
-				}
+			moving := false
+			layout.Flex{}.Layout(gtx,
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					editing := insp.editing(gtx)
+					moving = nav.layout(gtx, editing)
+					// NOTE(@lberg): playback changes the scene every frame, like moving the camera
+					moving = play.layout(gtx, editing) || moving
+					return vp.Layout(gtx)
+				}),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					return insp.Layout(gtx, th)
+				}),
+			)
+			engine.RepositionCamera(nav.Reposition)
+			if moving {
+				vp.moved(gtx.Now)
+				// NOTE(@lberg): held keys keep moving the camera,
+				// ask for another frame to integrate the motion
+				gtx.Execute(op.InvalidateCmd{})
 			}
-
-			imageWidget.Src = paint.NewImageOp(img)
-			imageWidget.Layout(gtx)
 			e.Frame(gtx.Ops)
 		}
 	}
 }
+
+// spin returns a timeline turning the entity with the given handle once around K
+// in the given number of seconds
+func spin(h internal.Handle, seconds float64) *internal.Timeline {
+	// NOTE(@lberg): rotations are blended along the shortest arc, so a full turn needs
+	// keys less than half a turn apart
+	place := internal.Track[internal.Frame]{}
+	for idx := range 5 {
+		angle := internal.Radian(float64(idx) * math.Pi / 2)
+		place = append(place, internal.Key[internal.Frame]{
+			Time:  seconds * float64(idx) / 4,
+			Value: internal.ZeroFrame.Rotate(internal.NewLine(internal.Zero, internal.K), angle),
+		})
+	}
+	return &internal.Timeline{Entities: map[internal.Handle]internal.EntityTracks{h: {Place: place}}}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"image"
+	"lberg/gorender/internal"
+	"math"
+
+	"gioui.org/f32"
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+)
+
+// navKeys are the keys driving the camera, they move it for as long as they are held
+var navKeys = []key.Name{"W", "A", "S", "D", "Q", "E"}
+
+// modeKey toggles between orbit and fly navigation
+const modeKey = key.Name("F")
+
+// navigator feeds the keyboard and mouse input of the window to an internal.Navigator.
+// Both modes share the same bindings:
+//   - W/S forward and backward (dolly in orbit mode)
+//   - A/D left and right, Q/E down and up
+//   - left drag orbits (looks around in fly mode), right or middle drag pans
+//   - the wheel zooms (dollies in fly mode)
+type navigator struct {
+	*internal.Navigator
+	// drag state
+	dragging pointer.Buttons
+	dragPos  f32.Point
+	// pressPos and moved tell clicks apart from drags
+	pressPos f32.Point
+	moved    bool
+	// onClick is called when the primary button is released without dragging
+	onClick func(pos f32.Point)
+}
+
+func newNavigator(eye internal.Vector, scene internal.Box) *navigator {
+	return &navigator{Navigator: internal.NewNavigator(eye, scene)}
+}
+
+// layout registers the input area and consumes the pending events,
+// key events are dropped when ignoreKeys is set (e.g. while typing in a field).
+// It returns true while the camera is moving.
+func (n *navigator) layout(gtx layout.Context, ignoreKeys bool) bool {
+	area := clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops)
+	event.Op(gtx.Ops, n)
+	area.Pop()
+
+	filters := []event.Filter{
+		key.Filter{Name: modeKey},
+		pointer.Filter{
+			Target:  n,
+			Kinds:   pointer.Press | pointer.Drag | pointer.Release | pointer.Cancel | pointer.Scroll,
+			ScrollY: pointer.ScrollRange{Min: math.MinInt32, Max: math.MaxInt32},
+		},
+	}
+	for _, name := range navKeys {
+		filters = append(filters, key.Filter{Name: name, Optional: key.ModShift})
+	}
+	moved := false
+	for {
+		ev, ok := gtx.Event(filters...)
+		if !ok {
+			break
+		}
+		switch ev := ev.(type) {
+		case key.Event:
+			if !ignoreKeys {
+				n.keyEvent(ev)
+			}
+		case pointer.Event:
+			if ev.Kind == pointer.Press {
+				// take the focus away from any text field
+				gtx.Execute(key.FocusCmd{})
+			}
+			moved = n.pointerEvent(ev, gtx.Constraints.Max) || moved
+		}
+	}
+	if ignoreKeys {
+		n.Release()
+	}
+	return n.Update(gtx.Now) || moved
+}
+
+func (n *navigator) keyEvent(ev key.Event) {
+	if ev.Name == modeKey {
+		if ev.State == key.Press {
+			n.Toggle()
+		}
+		return
+	}
+	if m, ok := internal.NavMoveKey(rune(ev.Name[0])); ok {
+		n.Hold(m, ev.State == key.Press, ev.Modifiers.Contain(key.ModShift))
+	}
+}
+
+// pointerEvent returns true if the event moved the camera
+func (n *navigator) pointerEvent(ev pointer.Event, size image.Point) bool {
+	switch ev.Kind {
+	case pointer.Press:
+		n.dragging = ev.Buttons
+		n.dragPos = ev.Position
+		n.pressPos = ev.Position
+		n.moved = false
+	case pointer.Release:
+		if !n.moved && n.dragging.Contain(pointer.ButtonPrimary) && n.onClick != nil {
+			n.onClick(ev.Position)
+		}
+		n.dragging = 0
+	case pointer.Cancel:
+		n.dragging = 0
+	case pointer.Drag:
+		delta := ev.Position.Sub(n.dragPos)
+		n.dragPos = ev.Position
+		if moved := ev.Position.Sub(n.pressPos); moved.X*moved.X+moved.Y*moved.Y > 9 {
+			n.moved = true
+		}
+		kind := internal.NavOrbitDrag
+		switch {
+		case n.dragging.Contain(pointer.ButtonPrimary):
+		case n.dragging.Contain(pointer.ButtonSecondary), n.dragging.Contain(pointer.ButtonTertiary):
+			kind = internal.NavPanDrag
+		default:
+			return false
+		}
+		n.Drag(kind, float64(delta.X), float64(delta.Y), float64(size.X))
+		return true
+	case pointer.Scroll:
+		n.Scroll(float64(ev.Scroll.Y))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"lberg/gorender/internal"
+	"math"
+	"time"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+)
+
+// playKey starts and pauses the animation
+const playKey = key.Name(key.NameSpace)
+
+// player plays the engine timeline in a loop, following the wall clock
+type player struct {
+	engine  *internal.Engine
+	playing bool
+	// start is when the animation time 0 was, or would have been, while playing
+	start time.Time
+}
+
+func newPlayer(engine *internal.Engine) *player {
+	return &player{engine: engine}
+}
+
+// layout consumes the pending key events and moves the animation to the current time,
+// key events are dropped when ignoreKeys is set. It returns true while playing.
+func (p *player) layout(gtx layout.Context, ignoreKeys bool) bool {
+	for {
+		ev, ok := gtx.Event(key.Filter{Name: playKey})
+		if !ok {
+			break
+		}
+		if ev, ok := ev.(key.Event); ok && ev.State == key.Press && !ignoreKeys {
+			p.toggle(gtx.Now)
+		}
+	}
+	if !p.playing {
+		return false
+	}
+	tl := p.engine.Timeline()
+	if tl == nil || tl.Duration() <= 0 {
+		p.playing = false
+		return false
+	}
+	p.engine.SetTime(math.Mod(gtx.Now.Sub(p.start).Seconds(), tl.Duration()))
+	return true
+}
+
+func (p *player) toggle(now time.Time) {
+	p.playing = !p.playing
+	if p.playing {
+		// NOTE(@lberg): resume from where the animation was paused
+		p.start = now.Add(-time.Duration(p.engine.Time() * float64(time.Second)))
+	}
+}
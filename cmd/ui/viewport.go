@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"image"
+	"lberg/gorender/internal"
+	"math"
+	"sync"
+	"time"
+
+	"gioui.org/app"
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op/paint"
+	"gioui.org/widget"
+)
+
+const (
+	// frameBudget is the time a frame may take while the camera moves
+	frameBudget = 30 * time.Millisecond
+	// settleDelay is how long after the last movement the full resolution comes back
+	settleDelay = 200 * time.Millisecond
+	// minScale bounds the resolution reduction while moving
+	minScale = 1. / 8
+)
+
+// viewport shows the engine render stretched over the area it is laid out in.
+// Renders follow the pixel size of the area so they stay sharp on HiDPI screens,
+// while the camera moves they use a lower resolution to keep up with the input.
+type viewport struct {
+	engine *internal.Engine
+	lock   sync.Mutex
+	// size is the area in pixels, as of the last layout
+	size     image.Point
+	lastMove time.Time
+	img      *image.RGBA
+	// pixelCost is an estimate of the render time of a single pixel
+	pixelCost time.Duration
+	// wake interrupts the wait for engine changes when the size or motion changes
+	wake chan struct{}
+}
+
+// renderKey identifies what a render shows, renders with the same key are identical
+type renderKey struct {
+	version uint64
+	size    image.Point
+	full    bool
+}
+
+func newViewport(engine *internal.Engine) *viewport {
+	return &viewport{engine: engine, wake: make(chan struct{}, 1)}
+}
+
+func (v *viewport) poke() {
+	select {
+	case v.wake <- struct{}{}:
+	default:
+	}
+}
+
+// moved tells the viewport the camera is moving, switching to the interactive resolution
+func (v *viewport) moved(now time.Time) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.lastMove = now
+	v.poke()
+}
+
+// run renders the engine until the program exits,
+// a new render only starts once the engine, the size or the resolution changed
+func (v *viewport) run(w *app.Window) {
+	var last renderKey
+	for {
+		v.lock.Lock()
+		size, sinceMove, cost := v.size, time.Since(v.lastMove), v.pixelCost
+		v.lock.Unlock()
+		moving := sinceMove < settleDelay
+		version, changed := v.engine.Changed()
+		key := renderKey{version, size, !moving}
+		if key == last || size.X <= 0 || size.Y <= 0 {
+			var settled <-chan time.Time
+			if moving {
+				settled = time.After(settleDelay - sinceMove)
+			}
+			select {
+			case <-changed:
+			case <-v.wake:
+			case <-settled:
+			}
+			continue
+		}
+		last = key
+
+		scale := 1.
+		if moving {
+			scale = interactiveScale(size, cost)
+		}
+		width := max(1, int(float64(size.X)*scale))
+		start := time.Now()
+		img, err := v.render(w, width, float64(size.X)/float64(size.Y), !moving, changed)
+		if err != nil {
+			// NOTE(@lberg): interrupted by a change, start over with the new state
+			last = renderKey{}
+			continue
+		}
+		elapsed := time.Since(start)
+
+		v.lock.Lock()
+		v.img = img
+		if pixels := img.Bounds().Dx() * img.Bounds().Dy(); pixels > 0 {
+			// NOTE(@lberg): smooth the estimate, single renders are noisy
+			sample := elapsed / time.Duration(pixels)
+			if v.pixelCost == 0 {
+				v.pixelCost = sample
+			} else {
+				v.pixelCost = (3*v.pixelCost + sample) / 4
+			}
+		}
+		v.lock.Unlock()
+		w.Invalidate()
+	}
+}
+
+// render renders the engine. Full resolution renders are slow so they are progressive,
+// showing each pass as it completes, and are interrupted by any change
+// as their result would already be outdated.
+func (v *viewport) render(w *app.Window, width int, ratio float64, full bool, changed <-chan struct{}) (*image.RGBA, error) {
+	if !full {
+		return v.engine.Render(context.Background(), width, ratio)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-changed:
+		case <-v.wake:
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+	return v.engine.Render(ctx, width, ratio, internal.WithProgress(8, func(img *image.RGBA, pass, passes int) bool {
+		if pass < passes {
+			// NOTE(@lberg): the image is still being written, show a copy
+			partial := image.NewRGBA(img.Bounds())
+			copy(partial.Pix, img.Pix)
+			v.lock.Lock()
+			v.img = partial
+			v.lock.Unlock()
+			w.Invalidate()
+		}
+		return true
+	}))
+}
+
+// interactiveScale returns the resolution scale fitting a render of size in the frame budget
+func interactiveScale(size image.Point, pixelCost time.Duration) float64 {
+	if pixelCost <= 0 {
+		return 0.25
+	}
+	pixels := float64(frameBudget) / float64(pixelCost)
+	scale := math.Sqrt(pixels / float64(size.X*size.Y))
+	return max(minScale, min(1, scale))
+}
+
+// pick returns the entity under pos, in pixels from the top left of the viewport
+func (v *viewport) pick(pos f32.Point) internal.Handle {
+	v.lock.Lock()
+	size := v.size
+	v.lock.Unlock()
+	if size.X <= 0 || size.Y <= 0 {
+		return ""
+	}
+	// NOTE(@lberg): pick at full resolution, whatever the resolution of the last render
+	h, _ := v.engine.Pick(float64(pos.X), float64(pos.Y), size.X, float64(size.X)/float64(size.Y))
+	return h
+}
+
+func (v *viewport) Layout(gtx layout.Context) layout.Dimensions {
+	size := gtx.Constraints.Max
+	v.lock.Lock()
+	if v.size != size {
+		v.size = size
+		v.poke()
+	}
+	img := v.img
+	v.lock.Unlock()
+
+	if img != nil {
+		gtx.Constraints.Min = size
+		widget.Image{Src: paint.NewImageOp(img), Fit: widget.Fill}.Layout(gtx)
+	}
+	return layout.Dimensions{Size: size}
+}
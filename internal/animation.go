@@ -0,0 +1,285 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"maps"
+	"math"
+	"slices"
+)
+
+// Interpolation is how a value goes from a key to the next one
+type Interpolation int
+
+const (
+	// LinearInterpolation moves at constant speed
+	LinearInterpolation Interpolation = iota
+	// StepInterpolation holds the value until the next key
+	StepInterpolation
+	// BezierInterpolation follows the timing curve of the key
+	BezierInterpolation
+)
+
+// Curve shapes the interpolation between two keys.
+// The zero value is linear.
+type Curve struct {
+	Interpolation Interpolation
+	// X1, Y1, X2, Y2 are the inner control points of the cubic Bézier timing curve
+	// going from (0, 0) to (1, 1), as in CSS cubic-bezier
+	X1, Y1, X2, Y2 float64
+}
+
+// Timing curves for BezierInterpolation
+var (
+	EaseIn    = Curve{BezierInterpolation, 0.42, 0, 1, 1}
+	EaseOut   = Curve{BezierInterpolation, 0, 0, 0.58, 1}
+	EaseInOut = Curve{BezierInterpolation, 0.42, 0, 0.58, 1}
+)
+
+// Eval maps the progress between two keys, in [0, 1], to the blend factor
+func (c Curve) Eval(t float64) float64 {
+	switch c.Interpolation {
+	case StepInterpolation:
+		return 0
+	case BezierInterpolation:
+		return c.bezier(t)
+	default:
+		return t
+	}
+}
+
+func (c Curve) bezier(t float64) float64 {
+	// NOTE(@lberg): both coordinates are cubics of the curve parameter s,
+	// find s giving x == t then return y(s)
+	cubic := func(p1, p2, s float64) float64 {
+		r := 1 - s
+		return 3*r*r*s*p1 + 3*r*s*s*p2 + s*s*s
+	}
+	lo, hi := 0., 1.
+	s := t
+	for range 8 {
+		x := cubic(c.X1, c.X2, s) - t
+		if math.Abs(x) < 1e-9 {
+			return cubic(c.Y1, c.Y2, s)
+		}
+		if x < 0 {
+			lo = s
+		} else {
+			hi = s
+		}
+		r := 1 - s
+		dx := 3*r*r*c.X1 + 6*r*s*(c.X2-c.X1) + 3*s*s*(1-c.X2)
+		if math.Abs(dx) < 1e-6 {
+			break
+		}
+		s -= x / dx
+		if s <= lo || s >= hi {
+			break
+		}
+	}
+	// Newton went astray, bisect what is left
+	for range 50 {
+		s = (lo + hi) / 2
+		if cubic(c.X1, c.X2, s) < t {
+			lo = s
+		} else {
+			hi = s
+		}
+	}
+	return cubic(c.Y1, c.Y2, (lo+hi)/2)
+}
+
+// Key is the value of a property at a time, in seconds
+type Key[T any] struct {
+	Time  float64
+	Value T
+	// Curve shapes the interpolation towards the next key
+	Curve Curve
+}
+
+// Track is a list of keys sorted by time, values between them are interpolated
+type Track[T any] []Key[T]
+
+// At returns the value at time t blending the surrounding keys with blend,
+// before the first key and after the last one the value holds still.
+// An empty track returns the zero value.
+func (tr Track[T]) At(t float64, blend func(a, b T, f float64) T) T {
+	if len(tr) == 0 {
+		var zero T
+		return zero
+	}
+	next, _ := slices.BinarySearchFunc(tr, t, func(k Key[T], t float64) int {
+		switch {
+		case k.Time < t:
+			return -1
+		case k.Time > t:
+			return 1
+		}
+		return 0
+	})
+	switch {
+	case next == len(tr):
+		return tr[len(tr)-1].Value
+	case next == 0 || tr[next].Time == t:
+		return tr[next].Value
+	}
+	k0, k1 := tr[next-1], tr[next]
+	// NOTE(@lberg): keep the key values as they are, blends may not give them back exactly
+	switch f := k0.Curve.Eval((t - k0.Time) / (k1.Time - k0.Time)); f {
+	case 0:
+		return k0.Value
+	case 1:
+		return k1.Value
+	default:
+		return blend(k0.Value, k1.Value, f)
+	}
+}
+
+// end returns the time of the last key
+func (tr Track[T]) end() float64 {
+	if len(tr) == 0 {
+		return 0
+	}
+	return tr[len(tr)-1].Time
+}
+
+// Lerp blends numbers linearly
+func Lerp[T ~float64](a, b T, f float64) T {
+	return a + (b-a)*T(f)
+}
+
+// LerpFrame blends the positions of two frames linearly and their orientations along the shortest arc
+func LerpFrame(a, b Frame, f float64) Frame {
+	q := Slerp(QuaternionFromFrame(a), QuaternionFromFrame(b), f)
+	return q.Frame(a.P.Add(b.P.Sub(a.P).Mul(f)))
+}
+
+// LerpColor blends colors in linear light, nil colors are held until the next key
+func LerpColor(a, b color.Color, f float64) color.Color {
+	if a == nil || b == nil {
+		if f < 1 {
+			return a
+		}
+		return b
+	}
+	c := LinearFromColor(a).Mul(float32(1 - f)).Add(LinearFromColor(b).Mul(float32(f)))
+	if c.A <= 0 {
+		return color.NRGBA{}
+	}
+	straight := c.Mul(1 / c.A)
+	return color.NRGBA{
+		encodeSRGB(straight.R), encodeSRGB(straight.G), encodeSRGB(straight.B), uint8(min(c.A, 1)*255 + 0.5),
+	}
+}
+
+// EntityTracks are the animated properties of an entity
+type EntityTracks struct {
+	// Place animates the placement, see Engine.Place
+	Place Track[Frame]
+	// Color animates the color override, see Engine.Recolor
+	Color Track[color.Color]
+}
+
+// Timeline animates the camera and the entities of an engine, see Engine.SetTime.
+// Properties without keys are left alone.
+type Timeline struct {
+	Camera   Track[Frame]
+	FOV      Track[Radian]
+	Entities map[Handle]EntityTracks
+}
+
+// Duration returns the time of the last key
+func (tl *Timeline) Duration() float64 {
+	d := max(tl.Camera.end(), tl.FOV.end())
+	for _, en := range tl.Entities {
+		d = max(d, en.Place.end(), en.Color.end())
+	}
+	return d
+}
+
+// apply sets the animated properties of s to their value at t,
+// it returns false if none changed
+func (tl *Timeline) apply(s *scene, t float64) bool {
+	changed := false
+	if len(tl.Camera) > 0 {
+		f := tl.Camera.At(t, LerpFrame)
+		changed = changed || f != s.camera.F
+		s.camera.F = f
+	}
+	if len(tl.FOV) > 0 {
+		fov := tl.FOV.At(t, Lerp)
+		changed = changed || fov != s.camera.HFov
+		s.camera.HFov = fov
+	}
+	cloned := false
+	for id, tracks := range tl.Entities {
+		en, ok := s.entities[id]
+		if !ok {
+			continue
+		}
+		moved, recolored := false, false
+		if len(tracks.Place) > 0 {
+			place := tracks.Place.At(t, LerpFrame)
+			moved = place != en.place
+			en.place = place
+		}
+		if len(tracks.Color) > 0 {
+			c := tracks.Color.At(t, LerpColor)
+			recolored = !same(c, en.color)
+			en.color = c
+		}
+		if !moved && !recolored {
+			continue
+		}
+		if !cloned {
+			s.entities = maps.Clone(s.entities)
+			cloned = true
+		}
+		s.entities[id] = en
+		changed = true
+	}
+	return changed
+}
+
+// Turntable returns a camera track going once around target in duration seconds,
+// starting from eye and keeping its height
+func Turntable(eye, target Vector, duration float64) Track[Frame] {
+	// NOTE(@lberg): positions are blended linearly, enough keys keep the path round
+	const keys = 72
+	o := NewOrbitController(eye, target)
+	tr := make(Track[Frame], 0, keys+1)
+	for idx := range keys + 1 {
+		tr = append(tr, Key[Frame]{Time: duration * float64(idx) / keys, Value: o.Frame()})
+		o.Orbit(2*math.Pi/keys, 0)
+	}
+	return tr
+}
+
+// RenderAnimation plays the timeline of e at fps frames per second and renders every frame,
+// frame is called in order with each image and stops the animation if it returns an error.
+// Frames are taken at idx/fps for idx from 0 to duration*fps excluded, so loops do not
+// repeat their first frame. The engine is left at the time of the last frame.
+func RenderAnimation(ctx context.Context, e *Engine, fps float64, width int, ratio float64,
+	frame func(idx int, img *image.RGBA) error, opts ...RenderOption) error {
+	tl := e.Timeline()
+	if tl == nil {
+		return fmt.Errorf("engine without timeline")
+	}
+	if fps <= 0 {
+		return fmt.Errorf("invalid frame rate %v", fps)
+	}
+	frames := max(1, int(math.Round(tl.Duration()*fps)))
+	for idx := range frames {
+		e.SetTime(float64(idx) / fps)
+		img, err := e.Render(ctx, width, ratio, opts...)
+		if err != nil {
+			return fmt.Errorf("frame %d: %w", idx, err)
+		}
+		if err := frame(idx, img); err != nil {
+			return fmt.Errorf("frame %d: %w", idx, err)
+		}
+	}
+	return nil
+}
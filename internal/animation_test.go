@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrack(t *testing.T) {
+	zAxis := Line{Zero, K}
+	tr := Track[Frame]{
+		{Time: 1, Value: ZeroFrame},
+		{Time: 3, Value: ZeroFrame.Move(I.Mul(2)).Rotate(zAxis, math.Pi/2)},
+	}
+	require.Equal(t, tr[0].Value, tr.At(0, LerpFrame))
+	require.Equal(t, tr[0].Value, tr.At(1, LerpFrame))
+	require.Equal(t, tr[1].Value, tr.At(3, LerpFrame))
+	require.Equal(t, tr[1].Value, tr.At(10, LerpFrame))
+
+	mid := tr.At(2, LerpFrame)
+	half := ZeroFrame.Rotate(zAxis, math.Pi/4)
+	require.InDeltaSlice(t, sl(half.I), sl(mid.I), 1e-9)
+	require.InDeltaSlice(t, sl(half.J), sl(mid.J), 1e-9)
+	require.InDeltaSlice(t, sl(K), sl(mid.K), 1e-9)
+	require.InDeltaSlice(t, sl(Vector{0, 1, 0}), sl(mid.P), 1e-9)
+
+	// slerp keeps a constant angular speed
+	quarter := tr.At(1.5, LerpFrame)
+	require.InDelta(t, math.Cos(math.Pi/8), quarter.I.X, 1e-9)
+
+	require.Equal(t, Frame{}, Track[Frame]{}.At(1, LerpFrame))
+
+	steps := Track[float64]{{Time: 0, Value: 1, Curve: Curve{Interpolation: StepInterpolation}}, {Time: 1, Value: 2}}
+	require.Equal(t, 1., steps.At(0.99, Lerp))
+	require.Equal(t, 2., steps.At(1, Lerp))
+
+	// the turntable comes back where it started, always looking at the target
+	target := Vector{1, 1, 0}
+	tt := Turntable(Vector{-4, 1, 2}, target, 6)
+	require.Equal(t, 6., tt[len(tt)-1].Time)
+	require.InDeltaSlice(t, sl(tt.At(0, LerpFrame).P), sl(tt.At(6, LerpFrame).P), 1e-9)
+	for _, at := range []float64{0.7, 1.5, 3, 4.1} {
+		f := tt.At(at, LerpFrame)
+		require.InDelta(t, 2, f.P.Z, 1e-9)
+		require.InDelta(t, 1, f.I.Dot(target.Sub(f.P).Normalize()), 1e-3)
+	}
+}
+
+func TestCurve(t *testing.T) {
+	for _, c := range []Curve{{}, EaseIn, EaseOut, EaseInOut, {BezierInterpolation, 0.1, 0.9, 0.9, 0.1}} {
+		require.InDelta(t, 0, c.Eval(0), 1e-9)
+		require.InDelta(t, 1, c.Eval(1), 1e-9)
+		prev := 0.
+		for idx := range 101 {
+			v := c.Eval(float64(idx) / 100)
+			require.GreaterOrEqual(t, v, prev-1e-9)
+			prev = v
+		}
+	}
+	require.Equal(t, 0.3, Curve{}.Eval(0.3))
+	require.InDelta(t, 0.5, EaseInOut.Eval(0.5), 1e-9)
+	require.Less(t, EaseIn.Eval(0.25), 0.25)
+	require.Greater(t, EaseOut.Eval(0.25), 0.25)
+	// a linear curve drawn as a Bézier
+	straight := Curve{BezierInterpolation, 1. / 3, 1. / 3, 2. / 3, 2. / 3}
+	require.InDelta(t, 0.37, straight.Eval(0.37), 1e-9)
+}
+
+func TestEngineSetTime(t *testing.T) {
+	engine := NewEngine()
+	cube, err := NewCube(1, 1, 1)
+	require.NoError(t, err)
+	h := engine.Add(&cube)[0]
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	engine.SetTimeline(&Timeline{
+		Camera: Track[Frame]{{Time: 0, Value: ZeroFrame.Move(I.Mul(-5))}},
+		FOV:    Track[Radian]{{Time: 0, Value: 1, Curve: EaseInOut}, {Time: 2, Value: 2}},
+		Entities: map[Handle]EntityTracks{h: {
+			Place: Track[Frame]{{Time: 0, Value: ZeroFrame}, {Time: 2, Value: ZeroFrame.Move(K.Mul(2))}},
+			Color: Track[color.Color]{{Time: 1, Value: red}, {Time: 2, Value: blue}},
+		}},
+	})
+	require.Equal(t, 2., engine.Timeline().Duration())
+	s := engine.snapshot()
+	require.Equal(t, Radian(1), s.camera.HFov)
+	require.Equal(t, -5., s.camera.F.P.X)
+
+	version := engine.Version()
+	engine.SetTime(1)
+	require.Equal(t, version+1, engine.Version())
+	require.Equal(t, 1., engine.Time())
+	s = engine.snapshot()
+	require.InDelta(t, 1.5, float64(s.camera.HFov), 1e-9)
+	info, _ := engine.Inspect(h)
+	require.InDeltaSlice(t, sl(K), sl(info.Position), 1e-9)
+	require.Equal(t, red, info.Color)
+
+	// the same time gives the same scene
+	engine.SetTime(1)
+	require.Equal(t, version+1, engine.Version())
+
+	// animated properties win over manual changes
+	engine.RepositionCamera(func(f Frame) Frame { return f.Move(K) })
+	engine.SetTime(1.5)
+	require.Equal(t, -5., engine.snapshot().camera.F.P.X)
+	require.Zero(t, engine.snapshot().camera.F.P.Z)
+	info, _ = engine.Inspect(h)
+	r, _, b, _ := info.Color.RGBA()
+	require.Equal(t, r, b, "half way in linear light")
+	require.Greater(t, r>>8, uint32(128))
+
+	// colors which cannot be compared are animated as well
+	engine.SetTimeline(&Timeline{Entities: map[Handle]EntityTracks{h: {
+		Color: Track[color.Color]{{Time: 0, Value: sliceColor{0, 0, 0xffff, 0xffff}}},
+	}}})
+	engine.SetTime(0)
+	info, _ = engine.Inspect(h)
+	require.Equal(t, sliceColor{0, 0, 0xffff, 0xffff}, info.Color)
+}
+
+func TestRenderAnimation(t *testing.T) {
+	engine := NewEngine()
+	cube, err := NewCube(1, 1, 1)
+	require.NoError(t, err)
+	h := engine.Add(&cube)[0]
+	require.Error(t, RenderAnimation(context.Background(), engine, 10, 32, 1, nil))
+	engine.SetTimeline(&Timeline{
+		Camera: Turntable(Vector{-5, 0, 0}, Zero, 1),
+		Entities: map[Handle]EntityTracks{h: {Place: Track[Frame]{
+			{Time: 0, Value: ZeroFrame},
+			{Time: 0.5, Value: ZeroFrame.Move(K)},
+		}}},
+	})
+
+	ge := NewGIFEncoder(10, 0)
+	var ids []int
+	var imgs []*image.RGBA
+	err = RenderAnimation(context.Background(), engine, 10, 32, 1, func(idx int, img *image.RGBA) error {
+		ids = append(ids, idx)
+		imgs = append(imgs, img)
+		return ge.Frame(idx, img)
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, ids)
+	info, _ := engine.Inspect(h)
+	require.InDeltaSlice(t, sl(K), sl(info.Position), 1e-9)
+
+	// playing the timeline by hand gives the same frames
+	engine.SetTime(0.3)
+	img, err := engine.Render(context.Background(), 32, 1)
+	require.NoError(t, err)
+	require.Equal(t, imgs[3].Pix, img.Pix)
+
+	var buf bytes.Buffer
+	require.NoError(t, ge.Encode(&buf))
+	anim, err := gif.DecodeAll(&buf)
+	require.NoError(t, err)
+	require.Len(t, anim.Image, 10)
+	require.Equal(t, 10, anim.Delay[0])
+	require.Equal(t, 0, anim.LoopCount)
+	require.Equal(t, image.Rect(0, 0, 32, 32), anim.Image[0].Bounds())
+
+	// frame errors stop the animation
+	dir := t.TempDir()
+	err = RenderAnimation(context.Background(), engine, 4, 16, 1, func(idx int, img *image.RGBA) error {
+		if idx == 2 {
+			return fmt.Errorf("stop")
+		}
+		return PNGSequence(filepath.Join(dir, "f%03d.png"))(idx, img)
+	})
+	require.EqualError(t, err, "frame 2: stop")
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Equal(t, "f001.png", files[1].Name())
+}
+
+func TestQuantize(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	for idx := range img.Pix {
+		img.Pix[idx] = uint8(idx * 7)
+	}
+	p := quantize(img)
+	require.LessOrEqual(t, len(p.Palette), 256)
+	// the most common colors are kept as they are
+	flat := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for idx := range flat.Pix {
+		flat.Pix[idx] = []uint8{200, 40, 8, 255}[idx%4]
+	}
+	q := quantize(flat)
+	require.Len(t, q.Palette, 1)
+	r, g, b, a := q.At(2, 2).RGBA()
+	require.Equal(t, []uint32{200, 40, 8, 255}, []uint32{r >> 8, g >> 8, b >> 8, a >> 8})
+}
@@ -0,0 +1,261 @@
+package internal
+
+import (
+	"hash/fnv"
+	"image"
+	"image/color"
+	"math"
+)
+
+// AOV selects auxiliary outputs produced alongside the beauty image,
+// values can be combined with |
+type AOV int
+
+const (
+	// DepthAOV is the distance from the camera along its view axis
+	DepthAOV AOV = 1 << iota
+	// NormalAOV is the world space surface normal
+	NormalAOV
+	// IDAOV is the entity seen in each pixel
+	IDAOV
+	// PositionAOV is the world space point hit
+	PositionAOV
+	// WhereAOV tells edges and corners from the inside of faces
+	WhereAOV
+
+	AllAOVs = DepthAOV | NormalAOV | IDAOV | PositionAOV | WhereAOV
+)
+
+// AOVs are the auxiliary buffers of a render, buffers which were not requested are nil.
+// Pixels where nothing was hit have an infinite depth, zero vectors, id 0 and no where.
+type AOVs struct {
+	Depth    *Buffer[float64]
+	Normal   *Buffer[Vector]
+	Position *Buffer[Vector]
+	// ID holds indexes in IDs, the entity ids of the render, 0 is no entity
+	ID    *Buffer[uint32]
+	IDs   []string
+	Where *Buffer[IntersectionType]
+	// viewAxis is the camera I, used to turn distances into depths
+	viewAxis Vector
+}
+
+// WithAOVs fills dst with the selected auxiliary buffers during the render
+func WithAOVs(dst *AOVs, which AOV) RenderOption {
+	return func(rc *renderConfig) {
+		rc.aovs = dst
+		rc.aovKinds = which
+	}
+}
+
+func (a *AOVs) init(r image.Rectangle, which AOV, viewAxis Vector, objs []Renderable) {
+	*a = AOVs{viewAxis: viewAxis}
+	if which&DepthAOV != 0 {
+		a.Depth = NewBuffer[float64](r)
+		a.Depth.Fill(r, math.Inf(1))
+	}
+	if which&NormalAOV != 0 {
+		a.Normal = NewBuffer[Vector](r)
+	}
+	if which&PositionAOV != 0 {
+		a.Position = NewBuffer[Vector](r)
+	}
+	if which&IDAOV != 0 {
+		a.ID = NewBuffer[uint32](r)
+		a.IDs = make([]string, len(objs)+1)
+		for idx, obj := range objs {
+			a.IDs[idx+1] = obj.ID()
+		}
+	}
+	if which&WhereAOV != 0 {
+		a.Where = NewBuffer[IntersectionType](r)
+	}
+}
+
+// fill records the intersection of the ray l with the object objs[hit] over r
+func (a *AOVs) fill(r image.Rectangle, l *Line, inter *Intersection, hit int) {
+	if inter == nil {
+		// NOTE(@lberg): progressive passes may cover pixels of a coarser one
+		if a.Depth != nil {
+			a.Depth.Fill(r, math.Inf(1))
+		}
+		for _, b := range []*Buffer[Vector]{a.Normal, a.Position} {
+			if b != nil {
+				b.Fill(r, Zero)
+			}
+		}
+		if a.ID != nil {
+			a.ID.Fill(r, 0)
+		}
+		if a.Where != nil {
+			a.Where.Fill(r, 0)
+		}
+		return
+	}
+	if a.Depth != nil {
+		a.Depth.Fill(r, inter.SignedDist*l.Dir.Dot(a.viewAxis))
+	}
+	if a.Normal != nil {
+		a.Normal.Fill(r, inter.Normal)
+	}
+	if a.Position != nil {
+		a.Position.Fill(r, inter.IntPoint)
+	}
+	if a.ID != nil {
+		a.ID.Fill(r, uint32(hit+1))
+	}
+	if a.Where != nil {
+		a.Where.Fill(r, inter.Where)
+	}
+}
+
+// IDAt returns the id of the entity seen in (x, y), empty if none
+func (a *AOVs) IDAt(x, y int) string {
+	if a.ID == nil {
+		return ""
+	}
+	return a.IDs[a.ID.At(x, y)]
+}
+
+// DepthImage maps the depth to grey levels, from black on the closest
+// hit to white on the farthest, pixels without hits are white as well
+func (a *AOVs) DepthImage() *image.Gray16 {
+	img := image.NewGray16(a.Depth.Rect)
+	near, far := math.Inf(1), math.Inf(-1)
+	for _, d := range a.Depth.Pix {
+		if !math.IsInf(d, 1) {
+			near, far = min(near, d), max(far, d)
+		}
+	}
+	span := max(far-near, Eps)
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			v := 1.
+			if d := a.Depth.At(x, y); !math.IsInf(d, 1) {
+				v = (d - near) / span
+			}
+			img.SetGray16(x, y, color.Gray16{uint16(math.Round(v * 0xffff))})
+		}
+	}
+	return img
+}
+
+// NormalImage maps normal components from [-1, 1] to [0, 255],
+// pixels without hits are transparent
+func (a *AOVs) NormalImage() *image.NRGBA {
+	img := image.NewNRGBA(a.Normal.Rect)
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			n := a.Normal.At(x, y)
+			if n == Zero {
+				continue
+			}
+			c := func(v float64) uint8 {
+				return uint8(math.Round((max(-1, min(1, v)) + 1) / 2 * 255))
+			}
+			img.SetNRGBA(x, y, color.NRGBA{c(n.X), c(n.Y), c(n.Z), 255})
+		}
+	}
+	return img
+}
+
+// PositionImage maps positions to colors, each axis normalized to the hit points bounds.
+// Pixels without hits are transparent.
+func (a *AOVs) PositionImage() *image.NRGBA {
+	img := image.NewNRGBA(a.Position.Rect)
+	box := EmptyBox()
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if a.hit(x, y) {
+				box = box.Extend(a.Position.At(x, y))
+			}
+		}
+	}
+	c := func(v, lo, hi float64) uint8 {
+		return uint8(math.Round((v - lo) / max(hi-lo, Eps) * 255))
+	}
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if !a.hit(x, y) {
+				continue
+			}
+			p := a.Position.At(x, y)
+			img.SetNRGBA(x, y, color.NRGBA{
+				c(p.X, box.Min.X, box.Max.X), c(p.Y, box.Min.Y, box.Max.Y), c(p.Z, box.Min.Z, box.Max.Z), 255,
+			})
+		}
+	}
+	return img
+}
+
+// hit tells if something was hit in (x, y) using whatever buffer is available
+func (a *AOVs) hit(x, y int) bool {
+	switch {
+	case a.ID != nil:
+		return a.ID.At(x, y) != 0
+	case a.Where != nil:
+		return a.Where.At(x, y) != 0
+	case a.Depth != nil:
+		return !math.IsInf(a.Depth.At(x, y), 1)
+	case a.Normal != nil:
+		return a.Normal.At(x, y) != Zero
+	}
+	return a.Position.At(x, y) != Zero
+}
+
+// IDImage paints every entity with a color derived from its id,
+// so the same entity keeps its color across renders
+func (a *AOVs) IDImage() *image.NRGBA {
+	palette := make([]color.NRGBA, len(a.IDs))
+	for idx, id := range a.IDs[1:] {
+		h := fnv.New32a()
+		h.Write([]byte(id))
+		v := h.Sum32()
+		palette[idx+1] = color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}
+	}
+	img := image.NewNRGBA(a.ID.Rect)
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			img.SetNRGBA(x, y, palette[a.ID.At(x, y)])
+		}
+	}
+	return img
+}
+
+// WhereImage paints faces grey, edges white and corners red
+func (a *AOVs) WhereImage() *image.NRGBA {
+	colors := map[IntersectionType]color.NRGBA{
+		inside: {128, 128, 128, 255},
+		edge:   {255, 255, 255, 255},
+		corner: {255, 0, 0, 255},
+	}
+	img := image.NewNRGBA(a.Where.Rect)
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			img.SetNRGBA(x, y, colors[a.Where.At(x, y)])
+		}
+	}
+	return img
+}
+
+// Images returns the images of all the available buffers keyed by name
+// (depth, normal, id, position and where)
+func (a *AOVs) Images() map[string]image.Image {
+	imgs := make(map[string]image.Image)
+	if a.Depth != nil {
+		imgs["depth"] = a.DepthImage()
+	}
+	if a.Normal != nil {
+		imgs["normal"] = a.NormalImage()
+	}
+	if a.ID != nil {
+		imgs["id"] = a.IDImage()
+	}
+	if a.Position != nil {
+		imgs["position"] = a.PositionImage()
+	}
+	if a.Where != nil {
+		imgs["where"] = a.WhereImage()
+	}
+	return imgs
+}
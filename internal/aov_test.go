@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderAOVs(t *testing.T) {
+	engine := NewEngine()
+	engine.RepositionCamera(func(f Frame) Frame {
+		return f.Move(I.Mul(-5))
+	})
+	cube, err := NewCube(1, 1, 1)
+	require.NoError(t, err)
+	engine.Add(&cube)
+
+	var aovs AOVs
+	_, err = engine.Render(context.Background(), 101, 1, WithAOVs(&aovs, AllAOVs))
+	require.NoError(t, err)
+
+	// the centre sees the front face of the cube
+	require.Equal(t, cube.ID(), aovs.IDAt(50, 50))
+	require.InDelta(t, 4.5, aovs.Depth.At(50, 50), 1e-6)
+	require.InDeltaSlice(t, sl(I.Neg()), sl(aovs.Normal.At(50, 50)), 1e-9)
+	require.InDelta(t, -0.5, aovs.Position.At(50, 50).X, 1e-6)
+	require.Equal(t, inside, aovs.Where.At(50, 50))
+	// depth is measured along the view axis, not along the ray
+	require.InDelta(t, 4.5, aovs.Depth.At(45, 55), 1e-6)
+
+	// nothing in the corner
+	require.Equal(t, "", aovs.IDAt(0, 0))
+	require.True(t, math.IsInf(aovs.Depth.At(0, 0), 1))
+	require.Equal(t, IntersectionType(0), aovs.Where.At(0, 0))
+
+	imgs := aovs.Images()
+	require.Len(t, imgs, 5)
+	for _, img := range imgs {
+		require.Equal(t, aovs.Depth.Rect, img.Bounds())
+	}
+
+	// only the requested buffers are there
+	_, err = engine.Render(context.Background(), 11, 1, WithAOVs(&aovs, DepthAOV))
+	require.NoError(t, err)
+	require.NotNil(t, aovs.Depth)
+	require.Nil(t, aovs.Normal)
+	require.Nil(t, aovs.ID)
+}
@@ -0,0 +1,98 @@
+package internal
+
+import "math"
+
+// Box is an axis aligned bounding box
+type Box struct {
+	Min, Max Vector
+}
+
+// EmptyBox returns a box containing nothing, extending it with any point
+// gives a box around that point
+func EmptyBox() Box {
+	inf := math.Inf(1)
+	return Box{Vector{inf, inf, inf}, Vector{-inf, -inf, -inf}}
+}
+
+func (b Box) Empty() bool {
+	return b.Min.X > b.Max.X || b.Min.Y > b.Max.Y || b.Min.Z > b.Max.Z
+}
+
+func (b Box) Extend(ps ...Vector) Box {
+	for _, p := range ps {
+		b.Min = Vector{min(b.Min.X, p.X), min(b.Min.Y, p.Y), min(b.Min.Z, p.Z)}
+		b.Max = Vector{max(b.Max.X, p.X), max(b.Max.Y, p.Y), max(b.Max.Z, p.Z)}
+	}
+	return b
+}
+
+func (b Box) Union(o Box) Box {
+	if o.Empty() {
+		return b
+	}
+	return b.Extend(o.Min, o.Max)
+}
+
+func (b Box) Center() Vector {
+	return b.Min.Add(b.Max).Mul(0.5)
+}
+
+// Diagonal is the length of the box diagonal, 0 for empty boxes
+func (b Box) Diagonal() float64 {
+	if b.Empty() {
+		return 0
+	}
+	return b.Max.Sub(b.Min).Norm()
+}
+
+// Map returns the box containing the corners of b mapped by f,
+// which is exact for affine maps
+func (b Box) Map(f func(Vector) Vector) Box {
+	if b.Empty() {
+		return b
+	}
+	mapped := EmptyBox()
+	for _, x := range []float64{b.Min.X, b.Max.X} {
+		for _, y := range []float64{b.Min.Y, b.Max.Y} {
+			for _, z := range []float64{b.Min.Z, b.Max.Z} {
+				mapped = mapped.Extend(f(Vector{x, y, z}))
+			}
+		}
+	}
+	return mapped
+}
+
+// Hit tells if the line crosses the box, anywhere along its length.
+// Points on the faces count as inside so flat boxes can be hit.
+func (b Box) Hit(l *Line) bool {
+	_, _, ok := b.clip(l)
+	return ok
+}
+
+// clip returns the line parameters where the line enters and leaves the box,
+// infinite when the line never leaves it, false if it misses it
+func (b Box) clip(l *Line) (float64, float64, bool) {
+	if b.Empty() {
+		return 0, 0, false
+	}
+	// NOTE(@lberg): slab test, the line enters all the slabs before leaving any
+	tMin, tMax := math.Inf(-1), math.Inf(1)
+	for axis := range 3 {
+		p, dir := l.P.axis(axis), l.Dir.axis(axis)
+		lo, hi := b.Min.axis(axis)-Eps, b.Max.axis(axis)+Eps
+		if dir == 0 {
+			if p < lo || p > hi {
+				return 0, 0, false
+			}
+			continue
+		}
+		t0, t1 := (lo-p)/dir, (hi-p)/dir
+		tMin, tMax = max(tMin, min(t0, t1)), min(tMax, max(t0, t1))
+	}
+	return tMin, tMax, tMin <= tMax
+}
+
+// Bounded is implemented by renderables with a finite extent
+type Bounded interface {
+	Bounds() Box
+}
@@ -0,0 +1,54 @@
+package internal
+
+import "image"
+
+// Buffer is a rectangle of per pixel values, laid out like the images
+// of the image package
+type Buffer[T any] struct {
+	Pix    []T
+	Stride int
+	Rect   image.Rectangle
+}
+
+func NewBuffer[T any](r image.Rectangle) *Buffer[T] {
+	return &Buffer[T]{
+		Pix:    make([]T, r.Dx()*r.Dy()),
+		Stride: r.Dx(),
+		Rect:   r,
+	}
+}
+
+func (b *Buffer[T]) Bounds() image.Rectangle {
+	return b.Rect
+}
+
+func (b *Buffer[T]) offset(x, y int) int {
+	return (y-b.Rect.Min.Y)*b.Stride + (x - b.Rect.Min.X)
+}
+
+// At returns the value in (x, y), the zero value outside the bounds
+func (b *Buffer[T]) At(x, y int) T {
+	if !(image.Point{x, y}.In(b.Rect)) {
+		var zero T
+		return zero
+	}
+	return b.Pix[b.offset(x, y)]
+}
+
+func (b *Buffer[T]) Set(x, y int, v T) {
+	if !(image.Point{x, y}.In(b.Rect)) {
+		return
+	}
+	b.Pix[b.offset(x, y)] = v
+}
+
+// Fill sets all the pixels in r to v
+func (b *Buffer[T]) Fill(r image.Rectangle, v T) {
+	r = r.Intersect(b.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := b.Pix[b.offset(r.Min.X, y):b.offset(r.Max.X, y)]
+		for idx := range row {
+			row[idx] = v
+		}
+	}
+}
@@ -19,38 +19,40 @@ func (f Frame) Move(v Vector) Frame {
 }
 
 func (f Frame) Rotate(axis Line, angle Radian) Frame {
-	return Frame{f.I.Rotate(axis, angle), f.J.Rotate(axis, angle), f.K.Rotate(axis, angle), f.P.Rotate(axis, angle)}
+	// NOTE(@lberg): the axes are directions, only the origin turns around the axis point
+	dirAxis := Line{Zero, axis.Dir}
+	return Frame{f.I.Rotate(dirAxis, angle), f.J.Rotate(dirAxis, angle), f.K.Rotate(dirAxis, angle), f.P.Rotate(axis, angle)}
 }
 
-type RenderPool struct {
-	poolSize int
-	inChan   chan func() error
-	outChan  chan error
-	ctx      context.Context
+// ToWorld maps a point expressed in the frame to world coordinates
+func (f Frame) ToWorld(v Vector) Vector {
+	return f.P.Add(f.DirToWorld(v))
 }
 
-func newRenderPool(workerNum, expectedJobs int, ctx context.Context) *RenderPool {
-	return &RenderPool{
-		poolSize: workerNum,
-		inChan:   make(chan func() error, workerNum),
-		outChan:  make(chan error, expectedJobs),
-		ctx:      ctx,
-	}
+// ToLocal maps a point in world coordinates to the frame
+func (f Frame) ToLocal(v Vector) Vector {
+	return f.DirToLocal(v.Sub(f.P))
 }
 
-func (rp *RenderPool) Start() {
-	for range rp.poolSize {
-		go func() {
-			for {
-				select {
-				case <-rp.ctx.Done():
-					return
-				case work := <-rp.inChan:
-					rp.outChan <- work()
-				}
-			}
-		}()
-	}
+// DirToWorld is ToWorld for directions, which are not affected by the frame position
+func (f Frame) DirToWorld(v Vector) Vector {
+	return f.I.Mul(v.X).Add(f.J.Mul(v.Y)).Add(f.K.Mul(v.Z))
+}
+
+// DirToLocal is ToLocal for directions, which are not affected by the frame position
+func (f Frame) DirToLocal(v Vector) Vector {
+	return Vector{v.Dot(f.I), v.Dot(f.J), v.Dot(f.K)}
+}
+
+// FrameToWorld maps a frame expressed in f to world coordinates,
+// f being the parent of local
+func (f Frame) FrameToWorld(local Frame) Frame {
+	return Frame{f.DirToWorld(local.I), f.DirToWorld(local.J), f.DirToWorld(local.K), f.ToWorld(local.P)}
+}
+
+// FrameToLocal maps a frame in world coordinates to f, the inverse of FrameToWorld
+func (f Frame) FrameToLocal(world Frame) Frame {
+	return Frame{f.DirToLocal(world.I), f.DirToLocal(world.J), f.DirToLocal(world.K), f.ToLocal(world.P)}
 }
 
 type Camera struct {
@@ -70,67 +72,212 @@ func (c Camera) Rotate(axis Line, angle Radian) Camera {
 	return newC
 }
 
-// RenderPerspective generates an image using ray-tracing and perspective
-// perspective is achieved by using an image plane normal to camera I
-// in the JK plane with sizes matching the FOV.
-// and defining points there to match the pixels in the image
-func (c *Camera) RenderPerspective(width int, ratio float64, objs ...Renderable) *image.RGBA {
+// focDis is the distance of the image plane from the camera,
+// anything closer than it is not rendered
+const focDis = 0.03
+
+// screen maps image pixels to points on the camera image plane
+type screen struct {
+	width, height    int
+	start            Vector
+	hOffset, vOffset float64
+}
+
+func (c *Camera) screen(width int, ratio float64) screen {
 	height := int(float64(width) / ratio)
 	HFov, VFov := c.HFov, c.HFov/Radian(ratio)
 
-	render := image.NewRGBA(image.Rect(0, 0, width, height))
-
 	// NOTE(@lberg): this plane can be defined at any distance,
 	// it does not really change things as we always cover the full section
 	// of the cone (i.e. we use the FOV and not the focal distance)
 	// see https://docs.blender.org/manual/en/latest/render/cameras.html
 
-	focDis := 0.03
 	start := c.F.P.Add(c.F.I.Mul(focDis))
 	HOffset := focDis * math.Tan(float64(HFov)/2) / float64(width/2)
 	VOffset := focDis * math.Tan(float64(VFov)/2) / float64(height/2)
 	// move start to top left position
 	start = start.Add(c.F.K.Mul(VOffset * float64(height) / 2)).
 		Add(c.F.J.Mul(HOffset * float64(width) / 2))
-	ctx, cancel := context.WithCancel(context.Background())
-	pool := newRenderPool(16, width, ctx)
-	pool.Start()
-	defer cancel()
-
-	for idxH := range height {
-		pool.inChan <- func() error {
-			for idxW := range width {
-				// compute the 3D position of the pixel, we sub because of the
-				// we are top left in a right system
-				point := start.Sub(c.F.K.Mul(VOffset * float64(idxH))).
-					Sub(c.F.J.Mul(HOffset * float64(idxW)))
-				// build a line starting from camera and passing through the point
-				rayLine := NewLine(c.F.P, point.Sub(c.F.P))
-				var inter *Intersection
-				for _, obj := range objs {
-					newInter := obj.Intersect(&rayLine)
-					if newInter == nil {
+	return screen{width, height, start, HOffset, VOffset}
+}
+
+// project returns the position in the image of the point p in front of the camera cam,
+// the inverse of Camera.ray, and the inverse of its depth along cam.I
+func (s *screen) project(cam Frame, p Vector) (float64, float64, float64) {
+	rel := p.Sub(cam.P)
+	depth := rel.Dot(cam.I)
+	// NOTE(@lberg): the point is brought on the image plane and measured from its top left corner
+	onPlane := rel.Mul(focDis / depth)
+	start := s.start.Sub(cam.P).Sub(onPlane)
+	return start.Dot(cam.J) / s.hOffset, start.Dot(cam.K) / s.vOffset, 1 / depth
+}
+
+// ray builds the line starting from camera and passing through the pixel (x, y)
+func (c *Camera) ray(s *screen, x, y float64) Line {
+	// compute the 3D position of the pixel, we sub because of the
+	// we are top left in a right system
+	point := s.start.Sub(c.F.K.Mul(s.vOffset * y)).
+		Sub(c.F.J.Mul(s.hOffset * x))
+	return NewLine(c.F.P, point.Sub(c.F.P))
+}
+
+// trace returns the closest intersection in front of the image plane
+// and the index of the object it belongs to
+func trace(rayLine *Line, objs []Renderable) (*Intersection, int) {
+	var inter *Intersection
+	hit := -1
+	for idx, obj := range objs {
+		newInter := obj.Intersect(rayLine)
+		if newInter == nil {
+			continue
+		}
+		// if too close or behind just ignore the intersection
+		if newInter.SignedDist <= focDis {
+			continue
+		}
+		// if no current intersection or closer then current replace
+		if inter == nil || newInter.SignedDist < inter.SignedDist {
+			inter = newInter
+			hit = idx
+		}
+	}
+	return inter, hit
+}
+
+// Pick casts the ray passing through the point (x, y) of an image
+// of the given width and ratio, it returns the first object hit if any
+func (c *Camera) Pick(x, y float64, width int, ratio float64, objs ...Renderable) (Renderable, *Intersection) {
+	s := c.screen(width, ratio)
+	rayLine := c.ray(&s, x, y)
+	inter, hit := trace(&rayLine, objs)
+	if inter == nil {
+		return nil, nil
+	}
+	return objs[hit], inter
+}
+
+// RenderPerspective generates an image using ray-tracing and perspective
+// perspective is achieved by using an image plane normal to camera I
+// in the JK plane with sizes matching the FOV.
+// and defining points there to match the pixels in the image.
+// WithBackend switches to rasterization, which gives the same image.
+// The image is rendered in tiles, see RenderOption for the scheduling,
+// and the render stops early if ctx is done.
+func (c *Camera) RenderPerspective(ctx context.Context, width int, ratio float64, objs []Renderable, opts ...RenderOption) (*image.RGBA, error) {
+	rc := newRenderConfig(opts...)
+	fb, err := c.render(ctx, width, ratio, objs, &rc)
+	if err != nil {
+		return nil, err
+	}
+	return fb.Resolve(rc.toneMapping, rc.exposure), nil
+}
+
+// RenderHDR is RenderPerspective keeping the linear colors,
+// exposure and tone mapping only apply to the images given to progress callbacks
+func (c *Camera) RenderHDR(ctx context.Context, width int, ratio float64, objs []Renderable, opts ...RenderOption) (*Framebuffer, error) {
+	rc := newRenderConfig(opts...)
+	return c.render(ctx, width, ratio, objs, &rc)
+}
+
+func (c *Camera) render(ctx context.Context, width int, ratio float64, objs []Renderable, rc *renderConfig) (*Framebuffer, error) {
+	s := c.screen(width, ratio)
+	fb := NewFramebuffer(image.Rect(0, 0, width, s.height))
+	ts := tiles(fb.Bounds(), rc.tileSize, rc.order)
+	var preview *image.RGBA
+	if rc.aovs != nil {
+		rc.aovs.init(fb.Bounds(), rc.aovKinds, c.F.I, objs)
+	}
+	var depth *Buffer[float64]
+	// lined is fb with the lines of the coarse passes
+	var lined *Framebuffer
+	if rc.wireframe != nil {
+		depth = NewBuffer[float64](fb.Bounds())
+	}
+	var rast *rasterizer
+	if rc.backend == RasterBackend {
+		rast = newRasterizer(c, &s, objs, rc.tileSize)
+	}
+
+	steps := rc.steps()
+	for pass, step := range steps {
+		err := renderTiles(ctx, ts, rc.workers, func(ctx context.Context, tile image.Rectangle) error {
+			// NOTE(@lberg): align to the step so all passes agree on the grid,
+			// blocks are clipped to the tile as other workers own the rest
+			startX := (tile.Min.X + step - 1) / step * step
+			startY := (tile.Min.Y + step - 1) / step * step
+			// NOTE(@lberg): the rasterizer draws the whole tile at once, the ray tracer pixel by pixel
+			var samples []rasterSample
+			if rast != nil {
+				samples = rast.draw(tile, step)
+			}
+			for idxH := startY; idxH < tile.Max.Y; idxH += step {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				for idxW := startX; idxW < tile.Max.X; idxW += step {
+					// skip the pixels rendered by the previous pass
+					if pass > 0 && idxW%(2*step) == 0 && idxH%(2*step) == 0 {
 						continue
 					}
-					// if too close or behind just ignore the intersection
-					if newInter.SignedDist <= focDis {
-						continue
+					rayLine := c.ray(&s, float64(idxW), float64(idxH))
+					var inter *Intersection
+					var hit int
+					if rast != nil {
+						inter, hit = rast.at(samples, tile, idxW, idxH, &rayLine)
+					} else {
+						inter, hit = trace(&rayLine, objs)
 					}
-					// if no current intersection or closer then current replace
-					if inter == nil || newInter.SignedDist < inter.SignedDist {
-						inter = newInter
+					var col LinearColor
+					block := image.Rect(idxW, idxH, idxW+step, idxH+step).Intersect(tile)
+					switch {
+					case inter == nil:
+					case rc.wireframe == nil:
+						col = LinearFromColor(inter.Color)
+					case rc.wireframe.Surfaces:
+						col = LinearFromColor(inter.surfaceColor())
+					}
+					fb.Fill(block, col)
+					if depth != nil {
+						d := math.Inf(1)
+						if inter != nil {
+							d = inter.SignedDist * rayLine.Dir.Dot(c.F.I)
+						}
+						depth.Fill(block, d)
+					}
+					if rc.aovs != nil {
+						rc.aovs.fill(block, &rayLine, inter, hit)
 					}
-				}
-				if inter != nil {
-					render.Set(idxW, idxH, inter.Color)
 				}
 			}
 			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		shown := fb
+		switch {
+		case rc.wireframe == nil:
+		case step == 1:
+			rc.wireframe.draw(fb, depth, c.F, &s, objs)
+		case rc.progress != nil:
+			// NOTE(@lberg): the next pass keeps some pixels of this one,
+			// the lines go on a copy so they are not blended twice
+			if lined == nil {
+				lined = NewFramebuffer(fb.Bounds())
+			}
+			copy(lined.Pix, fb.Pix)
+			rc.wireframe.draw(lined, depth, c.F, &s, objs)
+			shown = lined
+		}
+		if rc.progress != nil {
+			if preview == nil {
+				preview = image.NewRGBA(fb.Bounds())
+			}
+			shown.ResolveInto(preview, fb.Bounds(), rc.toneMapping, rc.exposure)
+			if !rc.progress(preview, pass+1, len(steps)) {
+				return shown, nil
+			}
 		}
 	}
-
-	for range width {
-		<-pool.outChan
-	}
-	return render
+	return fb, nil
 }
@@ -0,0 +1,427 @@
+package internal
+
+import (
+	"math"
+	"time"
+	"unicode"
+)
+
+// maxPitch keeps controllers away from the poles where
+// the view direction becomes parallel to the up vector
+const maxPitch = Radian(math.Pi/2 - 1e-3)
+
+// LookAt builds a frame placed in eye with I pointing towards target.
+// K is kept as close as possible to up and J completes the right handed system,
+// so the result can be used directly as a camera frame.
+func LookAt(eye, target, up Vector) Frame {
+	i := target.Sub(eye).Normalize()
+	j := up.Cross(i)
+	if j.Norm() < 1e-9 {
+		// NOTE(@lberg): up is parallel to the view direction,
+		// any vector orthogonal to i is as good as another
+		j = K.Cross(i)
+		if j.Norm() < 1e-9 {
+			j = I.Cross(i)
+		}
+	}
+	j = j.Normalize()
+	k := i.Cross(j)
+	return Frame{i, j, k, eye}
+}
+
+// yawPitchDir returns the unit vector pointing towards yaw (around K, starting from I)
+// and pitch (positive looks down)
+func yawPitchDir(yaw, pitch Radian) Vector {
+	cy, sy := math.Cos(float64(yaw)), math.Sin(float64(yaw))
+	cp, sp := math.Cos(float64(pitch)), math.Sin(float64(pitch))
+	return Vector{cp * cy, cp * sy, -sp}
+}
+
+// dirYawPitch is the inverse of yawPitchDir
+func dirYawPitch(dir Vector) (Radian, Radian) {
+	dir = dir.Normalize()
+	yaw := Radian(math.Atan2(dir.Y, dir.X))
+	pitch := Radian(-math.Asin(max(-1, min(1, dir.Z))))
+	return yaw, clampPitch(pitch)
+}
+
+func clampPitch(p Radian) Radian {
+	return max(-maxPitch, min(maxPitch, p))
+}
+
+// OrbitController moves the camera on a sphere centred in Target.
+// The camera always looks at the target with K as up direction.
+type OrbitController struct {
+	Target Vector
+	// Yaw rotates around K, 0 means the camera looks along I
+	Yaw Radian
+	// Pitch is positive when the camera looks down on the target
+	Pitch    Radian
+	Distance float64
+	// MinDistance and MaxDistance bound Zoom, a zero MaxDistance means no bound
+	MinDistance, MaxDistance float64
+}
+
+// NewOrbitController returns a controller matching a camera placed in eye looking at target
+func NewOrbitController(eye, target Vector) *OrbitController {
+	yaw, pitch := dirYawPitch(target.Sub(eye))
+	return &OrbitController{
+		Target:      target,
+		Yaw:         yaw,
+		Pitch:       pitch,
+		Distance:    target.Sub(eye).Norm(),
+		MinDistance: 1e-3,
+	}
+}
+
+// Orbit rotates the camera around the target
+func (o *OrbitController) Orbit(dYaw, dPitch Radian) {
+	o.Yaw += dYaw
+	o.Pitch = clampPitch(o.Pitch + dPitch)
+}
+
+// Zoom scales the distance from the target, factors below 1 get closer
+func (o *OrbitController) Zoom(factor float64) {
+	o.Distance *= factor
+	o.Distance = max(o.Distance, o.MinDistance)
+	if o.MaxDistance > 0 {
+		o.Distance = min(o.Distance, o.MaxDistance)
+	}
+}
+
+// Pan moves the target (and so the camera) on the view plane,
+// right and up are in world units
+func (o *OrbitController) Pan(right, up float64) {
+	f := o.Frame()
+	// NOTE(@lberg): J points left in the camera frame
+	o.Target = o.Target.Add(f.J.Mul(-right)).Add(f.K.Mul(up))
+}
+
+// Eye returns the camera position
+func (o *OrbitController) Eye() Vector {
+	return o.Target.Sub(yawPitchDir(o.Yaw, o.Pitch).Mul(o.Distance))
+}
+
+// Frame returns the camera frame
+func (o *OrbitController) Frame() Frame {
+	return LookAt(o.Eye(), o.Target, K)
+}
+
+// Reposition discards the current frame in favour of the controller one,
+// it can be passed straight to Engine.RepositionCamera
+func (o *OrbitController) Reposition(Frame) Frame {
+	return o.Frame()
+}
+
+// FlyController is a first person camera with inertia.
+// Thrust accelerates the camera along its own axes, Damping slows it down
+// once the thrust is released.
+type FlyController struct {
+	Position Vector
+	Yaw      Radian
+	Pitch    Radian
+	Velocity Vector
+	// Acceleration is in world units per second squared at full thrust
+	Acceleration float64
+	// Damping is the exponential decay rate of the velocity, per second
+	Damping float64
+	// thrust is expressed in camera coordinates (forward, left, up)
+	thrust Vector
+}
+
+// NewFlyController returns a controller starting from frame f
+func NewFlyController(f Frame) *FlyController {
+	yaw, pitch := dirYawPitch(f.I)
+	return &FlyController{
+		Position:     f.P,
+		Yaw:          yaw,
+		Pitch:        pitch,
+		Acceleration: 10,
+		Damping:      5,
+	}
+}
+
+// Look rotates the view direction
+func (fc *FlyController) Look(dYaw, dPitch Radian) {
+	fc.Yaw += dYaw
+	fc.Pitch = clampPitch(fc.Pitch + dPitch)
+}
+
+// Thrust sets the input direction in camera space, each component in [-1, 1].
+// The value is kept until the next call so held keys can simply set it once.
+func (fc *FlyController) Thrust(forward, right, up float64) {
+	fc.thrust = Vector{forward, -right, up}
+	if fc.thrust.Norm() > 1 {
+		fc.thrust = fc.thrust.Normalize()
+	}
+}
+
+// Update integrates the motion over dt
+func (fc *FlyController) Update(dt time.Duration) {
+	secs := dt.Seconds()
+	if secs <= 0 {
+		return
+	}
+	f := fc.Frame()
+	acc := f.I.Mul(fc.thrust.X).Add(f.J.Mul(fc.thrust.Y)).Add(f.K.Mul(fc.thrust.Z)).Mul(fc.Acceleration)
+	fc.Velocity = fc.Velocity.Add(acc.Mul(secs)).Mul(math.Exp(-fc.Damping * secs))
+	if fc.thrust == Zero && fc.Velocity.Norm() < 1e-6 {
+		fc.Velocity = Zero
+	}
+	fc.Position = fc.Position.Add(fc.Velocity.Mul(secs))
+}
+
+// Moving is true while the camera has some thrust or residual velocity
+func (fc *FlyController) Moving() bool {
+	return fc.thrust != Zero || fc.Velocity != Zero
+}
+
+// Frame returns the camera frame
+func (fc *FlyController) Frame() Frame {
+	return LookAt(fc.Position, fc.Position.Add(yawPitchDir(fc.Yaw, fc.Pitch)), K)
+}
+
+// Reposition discards the current frame in favour of the controller one,
+// it can be passed straight to Engine.RepositionCamera
+func (fc *FlyController) Reposition(Frame) Frame {
+	return fc.Frame()
+}
+
+// NavMode is how a Navigator moves the camera
+type NavMode int
+
+const (
+	// OrbitNav turns around a target
+	OrbitNav NavMode = iota
+	// FlyNav moves freely, with inertia
+	FlyNav
+)
+
+func (m NavMode) String() string {
+	if m == FlyNav {
+		return "fly"
+	}
+	return "orbit"
+}
+
+// NavMove is a movement of the camera driven by a key
+type NavMove int
+
+const (
+	NavForward NavMove = iota
+	NavBackward
+	NavLeft
+	NavRight
+	NavDown
+	NavUp
+)
+
+// NavMoveKey returns the movement bound to the key r, in either case:
+// W/S forward and backward (dolly in orbit mode), A/D left and right, Q/E down and up
+func NavMoveKey(r rune) (NavMove, bool) {
+	switch unicode.ToUpper(r) {
+	case 'W':
+		return NavForward, true
+	case 'S':
+		return NavBackward, true
+	case 'A':
+		return NavLeft, true
+	case 'D':
+		return NavRight, true
+	case 'Q':
+		return NavDown, true
+	case 'E':
+		return NavUp, true
+	}
+	return 0, false
+}
+
+// NavDrag is what dragging the view does
+type NavDrag int
+
+const (
+	// NavOrbitDrag orbits, or looks around in fly mode
+	NavOrbitDrag NavDrag = iota
+	// NavPanDrag moves the camera on the view plane
+	NavPanDrag
+)
+
+// Navigator turns the input of a viewer into camera movements.
+// In orbit mode the camera turns around a target, in fly mode it moves freely.
+// Movements are held for as long as their key is, see Update,
+// or applied as steps for inputs which do not report released keys.
+type Navigator struct {
+	Mode  NavMode
+	Orbit *OrbitController
+	Fly   *FlyController
+	// Scale is the scene size, all speeds are relative to it
+	Scale float64
+	held  [NavUp + 1]bool
+	fast  bool
+	// last is the time of the last update, zero when idle
+	last time.Time
+}
+
+// NewNavigator returns a navigator orbiting around the centre of scene from eye
+func NewNavigator(eye Vector, scene Box) *Navigator {
+	target := Zero
+	scale := 1.
+	if !scene.Empty() {
+		target = scene.Center()
+		scale = max(scene.Diagonal(), 1e-3)
+	}
+	orbit := NewOrbitController(eye, target)
+	orbit.MaxDistance = 100 * scale
+	fly := NewFlyController(orbit.Frame())
+	fly.Damping = 6
+	return &Navigator{
+		Mode:  OrbitNav,
+		Orbit: orbit,
+		Fly:   fly,
+		Scale: scale,
+	}
+}
+
+// Reposition can be passed to Engine.RepositionCamera
+func (n *Navigator) Reposition(f Frame) Frame {
+	if n.Mode == FlyNav {
+		return n.Fly.Reposition(f)
+	}
+	return n.Orbit.Reposition(f)
+}
+
+// Toggle switches between orbit and fly, keeping the view
+func (n *Navigator) Toggle() {
+	if n.Mode == OrbitNav {
+		n.Fly.Position = n.Orbit.Eye()
+		n.Fly.Yaw, n.Fly.Pitch = n.Orbit.Yaw, n.Orbit.Pitch
+		n.Fly.Velocity = Zero
+		n.Mode = FlyNav
+		return
+	}
+	// keep looking at the same point, at the last orbit distance
+	f := n.Fly.Frame()
+	dist := n.Orbit.Distance
+	*n.Orbit = *NewOrbitController(f.P, f.P.Add(f.I.Mul(dist)))
+	n.Orbit.MaxDistance = 100 * n.Scale
+	n.Mode = OrbitNav
+}
+
+// Hold starts or stops a movement, fast ones are 4 times faster
+func (n *Navigator) Hold(m NavMove, held, fast bool) {
+	n.held[m] = held
+	n.fast = fast
+}
+
+// Release stops all the held movements
+func (n *Navigator) Release() {
+	clear(n.held[:])
+}
+
+// Step moves the camera as if m was held for d, without inertia
+func (n *Navigator) Step(m NavMove, d time.Duration, fast bool) {
+	var axes [NavUp + 1]bool
+	axes[m] = true
+	forward, right, up := navAxes(&axes)
+	secs := d.Seconds() * navSpeed(fast)
+	if n.Mode == FlyNav {
+		// NOTE(@lberg): one scene per second, the terminal velocity of held movements
+		f := n.Fly.Frame()
+		move := f.I.Mul(forward).Add(f.J.Mul(-right)).Add(f.K.Mul(up))
+		n.Fly.Position = n.Fly.Position.Add(move.Mul(n.Scale * secs))
+		return
+	}
+	n.orbitMove(forward, right, up, secs)
+}
+
+// Drag applies a drag of (dx, dy) pixels over a view width pixels wide
+func (n *Navigator) Drag(kind NavDrag, dx, dy, width float64) {
+	// a drag across the whole view is a full turn
+	width = max(width, 1)
+	dYaw := Radian(-2 * math.Pi * dx / width)
+	dPitch := Radian(2 * math.Pi * dy / width)
+	// pan so that the scene roughly follows the pointer
+	panScale := n.Scale / width
+	if n.Mode == OrbitNav {
+		panScale = 2 * n.Orbit.Distance / width
+	}
+	right, up := -dx*panScale, dy*panScale
+
+	switch {
+	case kind == NavOrbitDrag && n.Mode == OrbitNav:
+		n.Orbit.Orbit(dYaw, dPitch)
+	case kind == NavOrbitDrag:
+		// NOTE(@lberg): the view follows the pointer, with a lower
+		// sensitivity as a full turn per view is too fast to look around
+		n.Fly.Look(dYaw/4, dPitch/4)
+	case n.Mode == OrbitNav:
+		n.Orbit.Pan(right, up)
+	default:
+		f := n.Fly.Frame()
+		n.Fly.Position = n.Fly.Position.Add(f.J.Mul(-right)).Add(f.K.Mul(up))
+	}
+}
+
+// Scroll zooms, or dollies in fly mode, positive amounts go away from the scene
+func (n *Navigator) Scroll(amount float64) {
+	if n.Mode == OrbitNav {
+		n.Orbit.Zoom(math.Pow(1.002, amount))
+		return
+	}
+	f := n.Fly.Frame()
+	n.Fly.Position = n.Fly.Position.Add(f.I.Mul(-amount * n.Scale / 500))
+}
+
+// Update applies the held movements up to now, it returns true while the camera is moving
+func (n *Navigator) Update(now time.Time) bool {
+	forward, right, up := navAxes(&n.held)
+	active := forward != 0 || right != 0 || up != 0
+	if !active && (n.Mode == OrbitNav || !n.Fly.Moving()) {
+		n.last = time.Time{}
+		return false
+	}
+	dt := time.Duration(0)
+	if !n.last.IsZero() {
+		dt = min(now.Sub(n.last), 100*time.Millisecond)
+	}
+	n.last = now
+
+	if n.Mode == FlyNav {
+		// NOTE(@lberg): the terminal velocity is acceleration/damping,
+		// so this moves at one scene per second
+		n.Fly.Acceleration = n.Scale * n.Fly.Damping * navSpeed(n.fast)
+		n.Fly.Thrust(forward, right, up)
+		n.Fly.Update(dt)
+		return true
+	}
+	n.orbitMove(forward, right, up, dt.Seconds()*navSpeed(n.fast))
+	return true
+}
+
+// orbitMove dollies and pans the orbit for secs at full speed
+func (n *Navigator) orbitMove(forward, right, up, secs float64) {
+	n.Orbit.Zoom(math.Pow(0.25, forward*secs))
+	n.Orbit.Pan(right*n.Orbit.Distance*secs, up*n.Orbit.Distance*secs)
+}
+
+func navSpeed(fast bool) float64 {
+	if fast {
+		return 4
+	}
+	return 1
+}
+
+// navAxes sums the movements into forward, right and up components in [-1, 1]
+func navAxes(moves *[NavUp + 1]bool) (float64, float64, float64) {
+	axis := func(pos, neg NavMove) float64 {
+		v := 0.
+		if moves[pos] {
+			v++
+		}
+		if moves[neg] {
+			v--
+		}
+		return v
+	}
+	return axis(NavForward, NavBackward), axis(NavRight, NavLeft), axis(NavUp, NavDown)
+}
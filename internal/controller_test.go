@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookAt(t *testing.T) {
+	eye := Vector{-2, -2, 1}
+	f := LookAt(eye, Zero, K)
+	require.Equal(t, eye, f.P)
+	// I points to the target
+	require.InDeltaSlice(t, sl(eye.Neg().Normalize()), sl(f.I), 1e-9)
+	// the frame is orthonormal and right handed
+	require.InDelta(t, 0, f.I.Dot(f.J), 1e-9)
+	require.InDelta(t, 0, f.I.Dot(f.K), 1e-9)
+	require.InDelta(t, 1, f.J.Norm(), 1e-9)
+	require.InDeltaSlice(t, sl(f.K), sl(f.I.Cross(f.J)), 1e-9)
+	// K is tilted towards up
+	require.Greater(t, f.K.Dot(K), 0.)
+
+	// looking straight down must not degenerate
+	f = LookAt(K, Zero, K)
+	require.InDeltaSlice(t, sl(K.Neg()), sl(f.I), 1e-9)
+	require.InDelta(t, 1, f.J.Norm(), 1e-9)
+}
+
+func TestOrbitController(t *testing.T) {
+	target := Vector{1, 1, 0}
+	o := NewOrbitController(target.Add(I.Mul(-5)), target)
+	require.InDelta(t, 5, o.Distance, 1e-9)
+	require.InDeltaSlice(t, sl(I), sl(o.Frame().I), 1e-9)
+
+	// a quarter turn moves the camera on the J side
+	o.Orbit(math.Pi/2, 0)
+	eye := o.Eye()
+	require.InDeltaSlice(t, sl(target.Add(J.Mul(-5))), sl(eye), 1e-9)
+	f := o.Frame()
+	require.InDeltaSlice(t, sl(target.Sub(f.P).Normalize()), sl(f.I), 1e-9)
+
+	o.Zoom(0.5)
+	require.InDelta(t, 2.5, o.Eye().Sub(target).Norm(), 1e-9)
+
+	// pitch never flips over the poles
+	o.Orbit(0, 10)
+	require.Equal(t, maxPitch, o.Pitch)
+}
+
+func TestFlyController(t *testing.T) {
+	fc := NewFlyController(ZeroFrame)
+	fc.Thrust(1, 0, 0)
+	for range 10 {
+		fc.Update(100 * time.Millisecond)
+	}
+	require.Greater(t, fc.Position.X, 0.)
+	require.InDelta(t, 0, fc.Position.Y, 1e-9)
+
+	// without thrust the damping brings the camera to a stop
+	fc.Thrust(0, 0, 0)
+	for range 200 {
+		fc.Update(100 * time.Millisecond)
+	}
+	require.False(t, fc.Moving())
+	stop := fc.Position
+	fc.Update(time.Second)
+	require.Equal(t, stop, fc.Position)
+}
+
+func TestNavigator(t *testing.T) {
+	box := Box{Vector{-1, -1, -1}, Vector{1, 1, 1}}
+	n := NewNavigator(I.Mul(-5), box)
+	require.Equal(t, OrbitNav, n.Mode)
+	require.InDelta(t, box.Diagonal(), n.Scale, 1e-9)
+
+	// holding forward for a second dollies to a quarter of the distance
+	n.Hold(NavForward, true, false)
+	now := time.Now()
+	require.True(t, n.Update(now))
+	for range 10 {
+		now = now.Add(100 * time.Millisecond)
+		n.Update(now)
+	}
+	require.InDelta(t, 1.25, n.Orbit.Distance, 1e-9)
+	n.Hold(NavForward, false, false)
+	require.False(t, n.Update(now))
+
+	// steps move the same without holding
+	n.Step(NavBackward, time.Second, false)
+	require.InDelta(t, 5, n.Orbit.Distance, 1e-9)
+
+	// a drag across the view is a full turn, toggling keeps the view
+	yaw := n.Orbit.Yaw
+	n.Drag(NavOrbitDrag, -100, 0, 400)
+	require.InDelta(t, float64(yaw+math.Pi/2), float64(n.Orbit.Yaw), 1e-9)
+	f := n.Reposition(ZeroFrame)
+	n.Toggle()
+	require.Equal(t, FlyNav, n.Mode)
+	require.InDeltaSlice(t, sl(f.P), sl(n.Reposition(ZeroFrame).P), 1e-9)
+	require.InDeltaSlice(t, sl(f.I), sl(n.Reposition(ZeroFrame).I), 1e-9)
+
+	// flying steps move by a scene per second
+	n.Step(NavUp, time.Second, true)
+	require.InDelta(t, f.P.Z+4*n.Scale, n.Fly.Position.Z, 1e-9)
+	n.Toggle()
+	require.Equal(t, OrbitNav, n.Mode)
+
+	m, ok := NavMoveKey('q')
+	require.True(t, ok)
+	require.Equal(t, NavDown, m)
+	_, ok = NavMoveKey('F')
+	require.False(t, ok)
+}
+
+// sl collects v so non addressable vectors can be compared
+func sl(v Vector) []float64 {
+	return v.Slice()
+}
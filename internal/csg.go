@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"cmp"
+	"fmt"
+	"image/color"
+	"slices"
+)
+
+// CSGOp is the way a CSG combines its solids
+type CSGOp int
+
+const (
+	// CSGUnion keeps what is inside any of the solids
+	CSGUnion CSGOp = iota
+	// CSGIntersection keeps what is inside both solids
+	CSGIntersection
+	// CSGDifference keeps what is inside the first solid but not the second
+	CSGDifference
+)
+
+func (op CSGOp) String() string {
+	switch op {
+	case CSGUnion:
+		return "union"
+	case CSGIntersection:
+		return "intersection"
+	case CSGDifference:
+		return "difference"
+	}
+	return "unknown"
+}
+
+// keep tells if a point inside a and b as given is inside the combination
+func (op CSGOp) keep(inA, inB bool) bool {
+	switch op {
+	case CSGUnion:
+		return inA || inB
+	case CSGIntersection:
+		return inA && inB
+	case CSGDifference:
+		return inA && !inB
+	}
+	return false
+}
+
+// CSG combines two solids into a new one, which is a solid as well
+// so CSGs can be nested. Every surface keeps the color of the solid it comes from.
+// CSGs are made with NewCSG and must not be changed afterwards.
+type CSG struct {
+	Op   CSGOp
+	A, B Solid
+	// bounds are computed once, they are checked for every line
+	bounds Box
+	IDGen
+}
+
+// NewCSG combines a and b, instances must have a solid geometry
+func NewCSG(op CSGOp, a, b Solid) (*CSG, error) {
+	for _, s := range []Solid{a, b} {
+		if in, ok := s.(*Instance); ok && !in.IsSolid() {
+			return nil, fmt.Errorf("csg %s: instance %s of a geometry which is not a solid", op, in.ID())
+		}
+	}
+	c := &CSG{Op: op, A: a, B: b}
+	c.bounds = c.computeBounds()
+	return c, nil
+}
+
+func (c *CSG) Intervals(l *Line) []Interval {
+	type crossing struct {
+		inter *Intersection
+		enter bool
+		fromA bool
+	}
+	var crossings []crossing
+	for _, s := range []struct {
+		solid Solid
+		fromA bool
+	}{{c.A, true}, {c.B, false}} {
+		for _, iv := range s.solid.Intervals(l) {
+			crossings = append(crossings,
+				crossing{iv.In, true, s.fromA},
+				crossing{iv.Out, false, s.fromA},
+			)
+		}
+	}
+	// NOTE(@lberg): at the same distance entries go first,
+	// so solids touching each other leave no gap
+	slices.SortStableFunc(crossings, func(x, y crossing) int {
+		if d := cmp.Compare(x.inter.SignedDist, y.inter.SignedDist); d != 0 {
+			return d
+		}
+		switch {
+		case x.enter && !y.enter:
+			return -1
+		case !x.enter && y.enter:
+			return 1
+		}
+		return 0
+	})
+
+	var res []Interval
+	var in *Intersection
+	inA, inB := false, false
+	for _, cr := range crossings {
+		if cr.fromA {
+			inA = cr.enter
+		} else {
+			inB = cr.enter
+		}
+		keep := c.Op.keep(inA, inB)
+		if keep && in == nil {
+			in = cr.inter
+		} else if !keep && in != nil {
+			res = append(res, Interval{in, cr.inter})
+			in = nil
+		}
+	}
+	return res
+}
+
+func (c *CSG) Intersect(l *Line) *Intersection {
+	if b := c.bounds; !b.Empty() && !b.Hit(l) {
+		return nil
+	}
+	return nearest(c.Intervals(l))
+}
+
+// Color returns the color of the first solid
+func (c *CSG) Color() color.Color {
+	if cc, ok := c.A.(Colored); ok {
+		return cc.Color()
+	}
+	return nil
+}
+
+// Bounds returns a box containing the combination, empty if the solids are not Bounded
+func (c *CSG) Bounds() Box {
+	return c.bounds
+}
+
+func (c *CSG) computeBounds() Box {
+	a, okA := c.A.(Bounded)
+	b, okB := c.B.(Bounded)
+	switch {
+	case c.Op == CSGUnion && okA && okB:
+		return a.Bounds().Union(b.Bounds())
+	case c.Op == CSGIntersection && okA && okB:
+		ba, bb := a.Bounds(), b.Bounds()
+		return Box{
+			Vector{max(ba.Min.X, bb.Min.X), max(ba.Min.Y, bb.Min.Y), max(ba.Min.Z, bb.Min.Z)},
+			Vector{min(ba.Max.X, bb.Max.X), min(ba.Max.Y, bb.Max.Y), min(ba.Max.Z, bb.Max.Z)},
+		}
+	case c.Op != CSGUnion && okA:
+		return a.Bounds()
+	case c.Op == CSGIntersection && okB:
+		return b.Bounds()
+	}
+	return EmptyBox()
+}
@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// dists returns the entry and exit distances of the intervals
+func dists(ivs []Interval) [][2]float64 {
+	var res [][2]float64
+	for _, iv := range ivs {
+		res = append(res, [2]float64{iv.In.SignedDist, iv.Out.SignedDist})
+	}
+	return res
+}
+
+func requireIntervals(t *testing.T, expected [][2]float64, ivs []Interval) {
+	t.Helper()
+	got := dists(ivs)
+	require.Len(t, got, len(expected), "intervals %v", got)
+	for idx := range expected {
+		require.InDeltaSlice(t, expected[idx][:], got[idx][:], 1e-9, "intervals %v", got)
+	}
+}
+
+func TestSolids(t *testing.T) {
+	_, err := NewSphere(Zero, 0)
+	require.Error(t, err)
+	_, err = NewCylinder(Zero, Zero, 1)
+	require.Error(t, err)
+
+	sphere, err := NewSphere(Vector{0, 0, 1}, 2)
+	require.NoError(t, err)
+	l := NewLine(Vector{-5, 0, 1}, I)
+	requireIntervals(t, [][2]float64{{3, 7}}, sphere.Intervals(&l))
+	inter := sphere.Intersect(&l)
+	require.InDeltaSlice(t, sl(I.Neg()), sl(inter.Normal), 1e-9)
+	// from the inside the exit is visible, facing the origin of the line
+	l = NewLine(Vector{0, 0, 1}, I)
+	inter = sphere.Intersect(&l)
+	require.InDelta(t, 2, inter.SignedDist, 1e-9)
+	require.InDeltaSlice(t, sl(I.Neg()), sl(inter.Normal), 1e-9)
+
+	cyl, err := NewCylinder(Vector{0, 0, -1}, Vector{0, 0, 1}, 0.5, WithCylinderEdgeColor(color.Black))
+	require.NoError(t, err)
+	require.InDeltaSlice(t, sl(Vector{-0.5, -0.5, -1}), sl(cyl.Bounds().Min), 1e-9)
+	require.InDeltaSlice(t, sl(Vector{0.5, 0.5, 1}), sl(cyl.Bounds().Max), 1e-9)
+	// across the side, then along the axis through the caps
+	l = NewLine(Vector{-5, 0, 0}, I)
+	requireIntervals(t, [][2]float64{{4.5, 5.5}}, cyl.Intervals(&l))
+	l = NewLine(Vector{0.2, 0, 5}, K.Neg())
+	ivs := cyl.Intervals(&l)
+	requireIntervals(t, [][2]float64{{4, 6}}, ivs)
+	require.InDeltaSlice(t, sl(K), sl(ivs[0].In.Normal), 1e-9)
+	require.Equal(t, inside, ivs[0].In.Where)
+	// the rims of the caps are edges
+	l = NewLine(Vector{0.5, 0, 5}, K.Neg())
+	inter = cyl.Intersect(&l)
+	require.Equal(t, edge, inter.Where)
+	require.Equal(t, color.Color(color.Black), inter.Color)
+	l = NewLine(Vector{1, 0, 5}, K.Neg())
+	require.Nil(t, cyl.Intersect(&l))
+
+	cube, err := NewCube(2, 2, 2)
+	require.NoError(t, err)
+	l = NewLine(Vector{-5, 0.3, 0.2}, I)
+	requireIntervals(t, [][2]float64{{4, 6}}, cube.Intervals(&l))
+}
+
+func TestCSG(t *testing.T) {
+	green := color.RGBA{0, 255, 0, 255}
+	block, err := NewCube(2, 2, 2)
+	require.NoError(t, err)
+	drill, err := NewCylinder(Vector{0, 0, -2}, Vector{0, 0, 2}, 0.5, WithCylinderColor(green))
+	require.NoError(t, err)
+	part, err := NewCSG(CSGDifference, &block, &drill)
+	require.NoError(t, err)
+
+	// through the hole there is nothing
+	l := NewLine(Vector{0, 0, 5}, K.Neg())
+	require.Empty(t, part.Intervals(&l))
+	require.Nil(t, part.Intersect(&l))
+	// aside of it there is the block
+	l = NewLine(Vector{0.75, 0, 5}, K.Neg())
+	requireIntervals(t, [][2]float64{{4, 6}}, part.Intervals(&l))
+	// across it the block is split, the walls of the hole face the line origin
+	l = NewLine(Vector{-5, 0, 0}, I)
+	ivs := part.Intervals(&l)
+	requireIntervals(t, [][2]float64{{4, 4.5}, {5.5, 6}}, ivs)
+	require.InDeltaSlice(t, sl(I.Neg()), sl(ivs[0].Out.Normal), 1e-9)
+	require.Equal(t, color.Color(green), ivs[0].Out.Color)
+	require.InDeltaSlice(t, sl(I.Neg()), sl(ivs[1].In.Normal), 1e-9)
+	require.Equal(t, block.Color(), part.Color())
+
+	// CSGs nest, a ball in the hole
+	ball, err := NewSphere(Zero, 0.3)
+	require.NoError(t, err)
+	filled, err := NewCSG(CSGUnion, part, &ball)
+	require.NoError(t, err)
+	requireIntervals(t, [][2]float64{{4, 4.5}, {4.7, 5.3}, {5.5, 6}}, filled.Intervals(&l))
+	// a cube with rounded corners
+	round, err := NewSphere(Zero, 1.2)
+	require.NoError(t, err)
+	rounded, err := NewCSG(CSGIntersection, &block, &round)
+	require.NoError(t, err)
+	requireIntervals(t, [][2]float64{{4, 6}}, rounded.Intervals(&l))
+	corner := NewLine(Vector{-5, 0.6, 0.6}, I)
+	d := math.Sqrt(1.2*1.2 - 0.6*0.6 - 0.6*0.6)
+	requireIntervals(t, [][2]float64{{5 - d, 5 + d}}, rounded.Intervals(&corner))
+	require.InDeltaSlice(t, sl(Vector{-1, -1, -1}), sl(rounded.Bounds().Min), 1e-9)
+
+	// instances of solids are solids, touching copies merge
+	moved, err := NewInstance(part, Translation(I.Mul(2)))
+	require.NoError(t, err)
+	merged, err := NewCSG(CSGUnion, part, &moved)
+	require.NoError(t, err)
+	requireIntervals(t, [][2]float64{{4, 4.5}, {5.5, 6.5}, {7.5, 8}}, merged.Intervals(&l))
+	// instances of surfaces are not
+	tri, err := NewTriangle(Zero, I, J)
+	require.NoError(t, err)
+	flat, err := NewInstance(&tri, Translation(K))
+	require.NoError(t, err)
+	require.False(t, flat.IsSolid())
+	_, err = NewCSG(CSGUnion, part, &flat)
+	require.Error(t, err)
+
+	// rendered from above the hole is see-through
+	engine := NewEngine()
+	engine.Add(part)
+	engine.RepositionCamera(func(Frame) Frame {
+		return LookAt(Vector{0, 0, 5}, Zero, I)
+	})
+	picked, _ := engine.Pick(50, 50, 101, 1)
+	require.Empty(t, picked)
+	picked, inter := engine.Pick(60, 50, 101, 1)
+	require.Equal(t, Handle(part.ID()), picked)
+	require.InDelta(t, 1, inter.IntPoint.Z, 1e-9)
+}
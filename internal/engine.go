@@ -1,47 +1,376 @@
 package internal
 
 import (
+	"context"
 	"image"
+	"image/color"
+	"io"
+	"maps"
 	"math"
+	"reflect"
 	"sync"
 )
 
-// Engine represent the 3D world and the camera observing it
+// SelectionColor is used to highlight the selected entity
+var SelectionColor color.Color = color.RGBA{255, 160, 0, 255}
+
+// Engine represent the 3D world and the camera observing it.
+// It is safe for concurrent use, every change publishes a new immutable scene
+// so renders in progress keep working on the one they started with.
 type Engine struct {
-	camera   Camera
-	entities map[string]Renderable
-	lock     sync.Mutex
+	lock  sync.Mutex
+	scene *scene
+	// changed is closed and replaced at every new scene to wake up waiters
+	changed chan struct{}
+}
+
+// Handle identifies an entity of an Engine for all its life, even when its
+// renderable is replaced by Update. It is the id of the renderable added.
+type Handle string
+
+// EntityState is the part of an entity Update can change
+type EntityState struct {
+	// Renderable can be swapped, for a moved copy for instance.
+	// Replacing a node keeps its children attached to the handle.
+	Renderable Renderable
+	// Place is the placement relative to the parent node, see Engine.Place
+	Place Frame
+	// Color overrides the base color of the renderable when not nil, see Engine.Recolor
+	Color color.Color
+}
+
+// EntityInfo describes an entity of the engine
+type EntityInfo struct {
+	ID   Handle
+	Type string
+	// Parent is the node the entity belongs to, empty at the top level
+	Parent Handle
+	// Position is the centre of the entity bounds in world coordinates,
+	// the origin of the entity for the ones without bounds like nodes
+	Position Vector
+	Color    color.Color
 }
 
 func NewEngine() *Engine {
 	return &Engine{
-		camera:   Camera{ZeroFrame, math.Pi / 2},
-		entities: make(map[string]Renderable),
+		scene: &scene{
+			camera:   Camera{ZeroFrame, math.Pi / 2},
+			entities: make(map[Handle]entity),
+		},
+		changed: make(chan struct{}),
 	}
 }
 
-func (e *Engine) RepositionCamera(tr func(Frame) Frame) {
+// snapshot returns the current scene, which must not be modified
+func (e *Engine) snapshot() *scene {
 	e.lock.Lock()
 	defer e.lock.Unlock()
-	e.camera.F = tr(e.camera.F)
+	return e.scene
 }
 
-func (e *Engine) Add(rs ...Renderable) {
-	for _, r := range rs {
-		e.entities[r.ID()] = r
+// update applies edit to a copy of the current scene, which is published
+// only if edit returns true. Edits must clone the entities before changing them.
+func (e *Engine) update(edit func(s *scene) bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	next := e.scene.clone()
+	if !edit(next) {
+		return
 	}
+	next.version++
+	e.scene = next
+	close(e.changed)
+	e.changed = make(chan struct{})
 }
 
-func (e *Engine) Remove(r Renderable) {
-	delete(e.entities, r.ID())
+// Version returns a counter increased by any change of the scene or camera,
+// two renders with the same version produce the same image
+func (e *Engine) Version() uint64 {
+	return e.snapshot().version
 }
 
-func (e *Engine) Render(width int, ratio float64) *image.RGBA {
-	entities := make([]Renderable, 0, len(e.entities))
-	for _, e := range e.entities {
-		entities = append(entities, e)
-	}
+// Changed returns the current version and a channel
+// closed as soon as the version moves past it
+func (e *Engine) Changed() (uint64, <-chan struct{}) {
 	e.lock.Lock()
 	defer e.lock.Unlock()
-	return e.camera.RenderPerspective(width, ratio, entities...)
+	return e.scene.version, e.changed
+}
+
+// Wait blocks until the version moves past after and returns the new version.
+// It returns early with the context error if ctx is done first.
+func (e *Engine) Wait(ctx context.Context, after uint64) (uint64, error) {
+	for {
+		version, changed := e.Changed()
+		if version > after {
+			return version, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return version, ctx.Err()
+		}
+	}
+}
+
+func (e *Engine) RepositionCamera(tr func(Frame) Frame) {
+	e.update(func(s *scene) bool {
+		f := tr(s.camera.F)
+		if f == s.camera.F {
+			return false
+		}
+		s.camera.F = f
+		return true
+	})
+}
+
+// Add adds renderables at the top level of the scene and returns their handles.
+// The children of nodes are added as well, placed relative to their node,
+// their handles are their ids.
+func (e *Engine) Add(rs ...Renderable) []Handle {
+	if len(rs) == 0 {
+		return nil
+	}
+	handles := make([]Handle, len(rs))
+	e.update(func(s *scene) bool {
+		s.entities = maps.Clone(s.entities)
+		var add func(parent Handle, r Renderable) Handle
+		add = func(parent Handle, r Renderable) Handle {
+			h := Handle(r.ID())
+			en := entity{handle: h, r: r, parent: parent, place: ZeroFrame}
+			if n, ok := r.(*Node); ok {
+				en.place = n.Frame
+				for _, child := range n.Children {
+					add(h, child)
+				}
+			}
+			s.entities[h] = en
+			return h
+		}
+		for idx, r := range rs {
+			handles[idx] = add("", r)
+		}
+		return true
+	})
+	return handles
+}
+
+// Remove removes an entity, with all its descendants if it is a node
+func (e *Engine) Remove(h Handle) {
+	e.update(func(s *scene) bool {
+		if _, ok := s.entities[h]; !ok {
+			return false
+		}
+		// NOTE(@lberg): the current scene still has the entities of s,
+		// and keeps its children index when s changes below
+		ids := e.scene.subtree(h)
+		s.entities = maps.Clone(s.entities)
+		for _, id := range ids {
+			delete(s.entities, id)
+		}
+		if _, ok := s.entities[s.selected]; !ok {
+			s.selected = ""
+		}
+		return true
+	})
+}
+
+// edit changes the entity with the given handle through fn,
+// it returns false if the entity does not exist
+func (e *Engine) edit(h Handle, fn func(en *entity)) bool {
+	found := false
+	e.update(func(s *scene) bool {
+		en, ok := s.entities[h]
+		if !ok {
+			return false
+		}
+		found = true
+		old := en
+		fn(&en)
+		if en.place == old.place && en.parent == old.parent && same(en.r, old.r) && same(en.color, old.color) {
+			return false
+		}
+		s.entities = maps.Clone(s.entities)
+		s.entities[h] = en
+		return true
+	})
+	return found
+}
+
+// same reports whether a and b hold the same value,
+// values which cannot be compared, like slices, are different
+func same(a, b any) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return reflect.TypeOf(a) == reflect.TypeOf(b) && reflect.ValueOf(a).Comparable() && a == b
+}
+
+// Update changes the state of an entity in place, all at once, fn receives the current state.
+// The handle stays valid whatever fn does, queries and animations keep working.
+// It returns false if the entity does not exist.
+func (e *Engine) Update(h Handle, fn func(st *EntityState)) bool {
+	return e.edit(h, func(en *entity) {
+		st := EntityState{Renderable: en.r, Place: en.place, Color: en.color}
+		fn(&st)
+		if st.Renderable != nil {
+			en.r = st.Renderable
+		}
+		en.place, en.color = st.Place, st.Color
+	})
+}
+
+// Place moves the entity with the given id, and its descendants,
+// tr receives the current placement relative to the parent node,
+// the world for top level entities (ZeroFrame when just added).
+// It returns false if the entity does not exist.
+func (e *Engine) Place(h Handle, tr func(Frame) Frame) bool {
+	return e.edit(h, func(en *entity) {
+		en.place = tr(en.place)
+	})
+}
+
+// PlaceWorld is Place with the placement in world coordinates, whatever the parent
+func (e *Engine) PlaceWorld(h Handle, tr func(Frame) Frame) bool {
+	found := false
+	e.update(func(s *scene) bool {
+		en, ok := s.entities[h]
+		if !ok {
+			return false
+		}
+		found = true
+		// NOTE(@lberg): s is being edited, its cached placements cannot be used
+		parent := s.worldPlace(en.parent)
+		place := parent.FrameToLocal(tr(parent.FrameToWorld(en.place)))
+		if place == en.place {
+			return false
+		}
+		en.place = place
+		s.entities = maps.Clone(s.entities)
+		s.entities[h] = en
+		return true
+	})
+	return found
+}
+
+// Recolor overrides the base color of an entity, edge colors are kept.
+// It returns false if the entity does not exist.
+func (e *Engine) Recolor(h Handle, c color.Color) bool {
+	return e.edit(h, func(en *entity) {
+		en.color = c
+	})
+}
+
+// Select highlights the entity with the given handle, an empty handle clears the selection
+func (e *Engine) Select(h Handle) {
+	e.update(func(s *scene) bool {
+		if h == s.selected {
+			return false
+		}
+		s.selected = h
+		return true
+	})
+}
+
+func (e *Engine) Selected() Handle {
+	return e.snapshot().selected
+}
+
+// Inspect describes the entity with the given handle
+func (e *Engine) Inspect(h Handle) (EntityInfo, bool) {
+	en, ok := e.snapshot().placed()[h]
+	if !ok {
+		return EntityInfo{}, false
+	}
+	info := EntityInfo{
+		ID:       h,
+		Type:     reflect.Indirect(reflect.ValueOf(en.r)).Type().Name(),
+		Parent:   en.parent,
+		Position: en.place.P,
+		Color:    en.color,
+	}
+	if b := en.Bounds(); !b.Empty() {
+		info.Position = b.Center()
+	}
+	if c, ok := en.r.(Colored); ok && info.Color == nil {
+		info.Color = c.Color()
+	}
+	return info, true
+}
+
+// Bounds returns the box containing all the bounded entities
+func (e *Engine) Bounds() Box {
+	b := EmptyBox()
+	for _, en := range e.snapshot().placed() {
+		b = b.Union(en.Bounds())
+	}
+	return b
+}
+
+// SetTimeline animates the engine with tl, which must not be changed afterwards,
+// the animated properties are set to their value at the current time.
+// A nil timeline stops the animation, leaving everything where it is.
+func (e *Engine) SetTimeline(tl *Timeline) {
+	e.update(func(s *scene) bool {
+		s.timeline = tl
+		if tl != nil {
+			tl.apply(s, s.time)
+		}
+		return true
+	})
+}
+
+func (e *Engine) Timeline() *Timeline {
+	return e.snapshot().timeline
+}
+
+// SetTime moves the animation to t seconds, setting all the animated properties at once.
+// Animated properties override the changes made by other means, like RepositionCamera,
+// every time they are evaluated.
+func (e *Engine) SetTime(t float64) {
+	e.update(func(s *scene) bool {
+		changed := t != s.time
+		s.time = t
+		if s.timeline != nil && s.timeline.apply(s, t) {
+			changed = true
+		}
+		return changed
+	})
+}
+
+// Time returns the current time of the animation
+func (e *Engine) Time() float64 {
+	return e.snapshot().time
+}
+
+// Pick returns the entity visible in the point (x, y) of a render
+// with the given width and ratio, together with the intersection.
+// The handle is empty if there is nothing there.
+func (e *Engine) Pick(x, y float64, width int, ratio float64) (Handle, *Intersection) {
+	s := e.snapshot()
+	r, inter := s.camera.Pick(x, y, width, ratio, s.renderables()...)
+	if r == nil {
+		return "", nil
+	}
+	return r.(*entity).handle, inter
+}
+
+// Render returns the image seen by the camera with the given width and ratio.
+// The scene is the one at the time of the call, later changes do not affect the render.
+// It returns an error if ctx is done before the end of the render.
+func (e *Engine) Render(ctx context.Context, width int, ratio float64, opts ...RenderOption) (*image.RGBA, error) {
+	s := e.snapshot()
+	return s.camera.RenderPerspective(ctx, width, ratio, s.renderables(), opts...)
+}
+
+// WriteSVG draws the edges of the meshes seen by the camera, see Camera.WriteSVG
+func (e *Engine) WriteSVG(w io.Writer, width int, ratio float64, opts SVGOptions) error {
+	s := e.snapshot()
+	return s.camera.WriteSVG(w, width, ratio, s.renderables(), opts)
+}
+
+// RenderHDR is Render keeping the linear colors, see Camera.RenderHDR
+func (e *Engine) RenderHDR(ctx context.Context, width int, ratio float64, opts ...RenderOption) (*Framebuffer, error) {
+	s := e.snapshot()
+	return s.camera.RenderHDR(ctx, width, ratio, s.renderables(), opts...)
 }
@@ -1,6 +1,17 @@
 package internal
 
-import "testing"
+import (
+	"context"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
 
 func BenchmarkEngine(b *testing.B) {
 	engine := NewEngine()
@@ -16,7 +27,215 @@ func BenchmarkEngine(b *testing.B) {
 
 	engine.Add(cubes...)
 	for b.Loop() {
-		engine.Render(512, 1)
+		engine.Render(context.Background(), 512, 1)
 
 	}
 }
+
+func TestEnginePick(t *testing.T) {
+	engine := NewEngine()
+	engine.RepositionCamera(func(f Frame) Frame {
+		return f.Move(I.Mul(-5))
+	})
+	cube, err := NewCube(1, 1, 1)
+	require.NoError(t, err)
+	h := engine.Add(&cube)[0]
+
+	// the centre of the image looks at the cube
+	picked, inter := engine.Pick(50, 50, 100, 1)
+	require.Equal(t, h, picked)
+	require.InDelta(t, 4.5, inter.SignedDist, 1e-6)
+	// the corner does not
+	picked, _ = engine.Pick(0, 0, 100, 1)
+	require.Empty(t, picked)
+
+	// once placed aside the cube is not in the centre anymore
+	require.True(t, engine.Place(h, func(f Frame) Frame { return f.Move(J.Mul(3)) }))
+	picked, _ = engine.Pick(50, 50, 100, 1)
+	require.Empty(t, picked)
+	info, ok := engine.Inspect(h)
+	require.True(t, ok)
+	require.Equal(t, "Cube", info.Type)
+	require.InDeltaSlice(t, sl(J.Mul(3)), sl(info.Position), 1e-9)
+}
+
+func TestEngineVersion(t *testing.T) {
+	engine := NewEngine()
+	start := engine.Version()
+
+	// repositioning without moving is not a change
+	engine.RepositionCamera(func(f Frame) Frame { return f })
+	require.Equal(t, start, engine.Version())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err := engine.Wait(ctx, start)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	done := make(chan uint64)
+	go func() {
+		v, _ := engine.Wait(context.Background(), start)
+		done <- v
+	}()
+	cube, err := NewCube(1, 1, 1)
+	require.NoError(t, err)
+	h := engine.Add(&cube)[0]
+	require.Greater(t, <-done, start)
+
+	version := engine.Version()
+	engine.Place(h, func(f Frame) Frame { return f.Move(I) })
+	require.Greater(t, engine.Version(), version)
+
+	// colors are compared safely, those which cannot be compared are always a change
+	version = engine.Version()
+	engine.Recolor(h, color.RGBA{0, 255, 0, 255})
+	engine.Recolor(h, color.RGBA{0, 255, 0, 255})
+	require.Equal(t, version+1, engine.Version())
+	engine.Recolor(h, sliceColor{0, 0xffff, 0, 0xffff})
+	engine.Recolor(h, sliceColor{0, 0xffff, 0, 0xffff})
+	require.Equal(t, version+3, engine.Version())
+}
+
+// sliceColor is a color.Color which cannot be compared with ==
+type sliceColor []uint32
+
+func (c sliceColor) RGBA() (r, g, b, a uint32) {
+	return c[0], c[1], c[2], c[3]
+}
+
+func TestEngineConcurrentEdits(t *testing.T) {
+	engine := NewEngine()
+	engine.RepositionCamera(func(f Frame) Frame {
+		return f.Move(I.Mul(-5))
+	})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				cube, _ := NewCube(1, 1, 1)
+				h := engine.Add(&cube)[0]
+				engine.Place(h, func(f Frame) Frame { return f.Move(J) })
+				engine.Select(h)
+				engine.Remove(h)
+			}
+		}()
+	}
+	for range 10 {
+		_, err := engine.Render(context.Background(), 16, 1)
+		require.NoError(t, err)
+		engine.Pick(8, 8, 16, 1)
+	}
+	wg.Wait()
+	// every change got its own version
+	require.Equal(t, uint64(1+4*20*4), engine.Version())
+}
+
+func TestEngineSceneGraph(t *testing.T) {
+	// an arm along I: a shoulder at the origin, an upper link of length 2,
+	// an elbow at its end and a forearm of length 1
+	upper, err := NewCube(0.2, 0.2, 2)
+	require.NoError(t, err)
+	upper = upper.Transform(Translation(I))
+	fore, err := NewCube(0.2, 0.2, 1)
+	require.NoError(t, err)
+	fore = fore.Transform(Translation(I.Mul(0.5)))
+	elbow := NewNode(ZeroFrame.Move(I.Mul(2)), &fore)
+	shoulder := NewNode(ZeroFrame, &upper, elbow)
+
+	engine := NewEngine()
+	require.Equal(t, []Handle{Handle(shoulder.ID())}, engine.Add(shoulder))
+	info, ok := engine.Inspect(Handle(fore.ID()))
+	require.True(t, ok)
+	require.Equal(t, Handle(elbow.ID()), info.Parent)
+	require.InDeltaSlice(t, sl(I.Mul(2.5)), sl(info.Position), 1e-9)
+	require.InDeltaSlice(t, sl(Vector{3, 0.1, 0.1}), sl(engine.Bounds().Max), 1e-9)
+
+	// raising the shoulder moves the whole arm, bending the elbow only the forearm
+	zAxis := NewLine(Zero, K)
+	engine.Place(Handle(shoulder.ID()), func(f Frame) Frame { return f.Rotate(zAxis, math.Pi/2) })
+	engine.Place(Handle(elbow.ID()), func(f Frame) Frame {
+		return f.Rotate(NewLine(f.P, J), -math.Pi/2)
+	})
+	info, _ = engine.Inspect(Handle(upper.ID()))
+	require.InDeltaSlice(t, sl(J), sl(info.Position), 1e-9)
+	info, _ = engine.Inspect(Handle(fore.ID()))
+	require.InDeltaSlice(t, sl(Vector{0, 2, 0.5}), sl(info.Position), 1e-9)
+	info, _ = engine.Inspect(Handle(elbow.ID()))
+	require.Equal(t, "Node", info.Type)
+	require.InDeltaSlice(t, sl(J.Mul(2)), sl(info.Position), 1e-9)
+
+	// the hand is visible from above the arm
+	engine.RepositionCamera(func(Frame) Frame {
+		return LookAt(Vector{0, 2, 5}, Vector{0, 2, 0}, J)
+	})
+	picked, _ := engine.Pick(50, 50, 101, 1)
+	require.Equal(t, Handle(fore.ID()), picked)
+
+	// world placement through a rotated parent
+	engine.PlaceWorld(Handle(fore.ID()), func(f Frame) Frame { return f.Move(K) })
+	info, _ = engine.Inspect(Handle(fore.ID()))
+	require.InDeltaSlice(t, sl(Vector{0, 2, 1.5}), sl(info.Position), 1e-9)
+
+	// renderables come in handle order, whatever the order of the map
+	require.True(t, slices.IsSortedFunc(engine.snapshot().renderables(), func(a, b Renderable) int {
+		return strings.Compare(a.ID(), b.ID())
+	}))
+
+	// selecting a node highlights its subtree and removing it removes the subtree
+	engine.Select(Handle(elbow.ID()))
+	require.True(t, engine.snapshot().placed()[Handle(fore.ID())].selected)
+	require.False(t, engine.snapshot().placed()[Handle(upper.ID())].selected)
+	engine.Remove(Handle(elbow.ID()))
+	_, ok = engine.Inspect(Handle(fore.ID()))
+	require.False(t, ok)
+	require.Empty(t, engine.Selected())
+	_, ok = engine.Inspect(Handle(upper.ID()))
+	require.True(t, ok)
+}
+
+func TestEngineUpdate(t *testing.T) {
+	engine := NewEngine()
+	engine.RepositionCamera(func(f Frame) Frame {
+		return f.Move(I.Mul(-5))
+	})
+	cube, err := NewCube(1, 1, 1)
+	require.NoError(t, err)
+	h := engine.Add(&cube)[0]
+	require.Equal(t, Handle(cube.ID()), h)
+
+	// a moved copy has a new id but keeps the handle
+	version := engine.Version()
+	moved := cube.Transform(Translation(J.Mul(3)))
+	require.True(t, engine.Update(h, func(st *EntityState) {
+		st.Renderable = &moved
+		st.Color = color.RGBA{0, 0, 255, 255}
+	}))
+	require.Equal(t, version+1, engine.Version())
+	info, ok := engine.Inspect(h)
+	require.True(t, ok)
+	require.Equal(t, h, info.ID)
+	require.InDeltaSlice(t, sl(J.Mul(3)), sl(info.Position), 1e-9)
+	require.Equal(t, color.Color(color.RGBA{0, 0, 255, 255}), info.Color)
+
+	picked, _ := engine.Pick(50, 50, 100, 1)
+	require.Empty(t, picked)
+	require.True(t, engine.Update(h, func(st *EntityState) {
+		st.Place = st.Place.Move(J.Mul(-3))
+	}))
+	picked, _ = engine.Pick(50, 50, 100, 1)
+	require.Equal(t, h, picked)
+	var aovs AOVs
+	_, err = engine.Render(context.Background(), 100, 1, WithAOVs(&aovs, IDAOV))
+	require.NoError(t, err)
+	require.Equal(t, string(h), aovs.IDAt(50, 50))
+
+	// no change, no new version
+	version = engine.Version()
+	require.True(t, engine.Update(h, func(*EntityState) {}))
+	require.Equal(t, version, engine.Version())
+
+	engine.Remove(h)
+	require.False(t, engine.Update(h, func(*EntityState) {}))
+}
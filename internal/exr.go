@@ -0,0 +1,410 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"slices"
+	"strings"
+)
+
+// EXRChannel is a channel of an OpenEXR image, values are row major
+type EXRChannel struct {
+	Name string
+	Pix  []float32
+}
+
+// EXRImage is a multi channel float image as stored in OpenEXR files.
+// Channels named like layer.X are shown as layers by compositing tools.
+type EXRImage struct {
+	Rect     image.Rectangle
+	Channels []EXRChannel
+}
+
+// NewEXRImage collects the channels of a render, aovs may be nil.
+// The colors go to R, G, B and A, the AOVs to Z, N.X/Y/Z, P.X/Y/Z, id and where.
+// Ids are stored as floats, exact up to 2^24 entities.
+func NewEXRImage(fb *Framebuffer, aovs *AOVs) *EXRImage {
+	img := &EXRImage{Rect: fb.Rect}
+	n := fb.Rect.Dx() * fb.Rect.Dy()
+	add := func(name string, fn func(idx int) float32) {
+		pix := make([]float32, n)
+		for idx := range pix {
+			pix[idx] = fn(idx)
+		}
+		img.Channels = append(img.Channels, EXRChannel{name, pix})
+	}
+	// NOTE(@lberg): buffers are all allocated by NewBuffer, so they are packed
+	add("R", func(idx int) float32 { return fb.Pix[idx].R })
+	add("G", func(idx int) float32 { return fb.Pix[idx].G })
+	add("B", func(idx int) float32 { return fb.Pix[idx].B })
+	add("A", func(idx int) float32 { return fb.Pix[idx].A })
+	if aovs == nil {
+		return img
+	}
+	if aovs.Depth != nil {
+		add("Z", func(idx int) float32 { return float32(aovs.Depth.Pix[idx]) })
+	}
+	vectors := func(layer string, b *Buffer[Vector]) {
+		if b == nil {
+			return
+		}
+		add(layer+".X", func(idx int) float32 { return float32(b.Pix[idx].X) })
+		add(layer+".Y", func(idx int) float32 { return float32(b.Pix[idx].Y) })
+		add(layer+".Z", func(idx int) float32 { return float32(b.Pix[idx].Z) })
+	}
+	vectors("N", aovs.Normal)
+	vectors("P", aovs.Position)
+	if aovs.ID != nil {
+		add("id", func(idx int) float32 { return float32(aovs.ID.Pix[idx]) })
+	}
+	if aovs.Where != nil {
+		add("where", func(idx int) float32 { return float32(aovs.Where.Pix[idx]) })
+	}
+	return img
+}
+
+// Channel returns the values of the named channel, nil if there is none
+func (img *EXRImage) Channel(name string) []float32 {
+	for _, ch := range img.Channels {
+		if ch.Name == name {
+			return ch.Pix
+		}
+	}
+	return nil
+}
+
+// Framebuffer returns the R, G, B and A channels, missing channels are 0 but for A which is 1
+func (img *EXRImage) Framebuffer() *Framebuffer {
+	fb := NewFramebuffer(img.Rect)
+	r, g, b, a := img.Channel("R"), img.Channel("G"), img.Channel("B"), img.Channel("A")
+	at := func(ch []float32, idx int, def float32) float32 {
+		if ch == nil {
+			return def
+		}
+		return ch[idx]
+	}
+	for idx := range fb.Pix {
+		fb.Pix[idx] = LinearColor{at(r, idx, 0), at(g, idx, 0), at(b, idx, 0), at(a, idx, 1)}
+	}
+	return fb
+}
+
+const (
+	exrMagic   = 20000630
+	exrVersion = 2
+	// exrUnsupported are the version flags for tiled, deep and multi part files
+	exrUnsupported = 0x200 | 0x800 | 0x1000
+	// exrLongNames allows names up to 255 bytes instead of 31
+	exrLongNames = 0x400
+
+	exrUint  = 0
+	exrHalf  = 1
+	exrFloat = 2
+)
+
+// WriteEXR writes img as an uncompressed scanline OpenEXR file with 32 bit float channels
+func WriteEXR(w io.Writer, img *EXRImage) error {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("empty exr image %v", img.Rect)
+	}
+	// NOTE(@lberg): the spec wants channels sorted by name, in the header and in the pixels
+	chs := slices.Clone(img.Channels)
+	slices.SortFunc(chs, func(a, b EXRChannel) int { return strings.Compare(a.Name, b.Name) })
+	for idx, ch := range chs {
+		if ch.Name == "" || len(ch.Name) > 255 || strings.ContainsRune(ch.Name, 0) {
+			return fmt.Errorf("invalid exr channel name %q", ch.Name)
+		}
+		if idx > 0 && ch.Name == chs[idx-1].Name {
+			return fmt.Errorf("duplicate exr channel %q", ch.Name)
+		}
+		if len(ch.Pix) != width*height {
+			return fmt.Errorf("exr channel %q has %d values, want %d", ch.Name, len(ch.Pix), width*height)
+		}
+	}
+
+	le := binary.LittleEndian
+	var head bytes.Buffer
+	u32 := func(v uint32) { head.Write(le.AppendUint32(nil, v)) }
+	f32 := func(v float32) { u32(math.Float32bits(v)) }
+	attr := func(name, typ string, size int) {
+		head.WriteString(name + "\x00" + typ + "\x00")
+		u32(uint32(size))
+	}
+	box := func(name string) {
+		attr(name, "box2i", 16)
+		for _, v := range []int{img.Rect.Min.X, img.Rect.Min.Y, img.Rect.Max.X - 1, img.Rect.Max.Y - 1} {
+			u32(uint32(int32(v)))
+		}
+	}
+
+	version := uint32(exrVersion)
+	for _, ch := range chs {
+		if len(ch.Name) > 31 {
+			version |= exrLongNames
+		}
+	}
+	u32(exrMagic)
+	u32(version)
+	size := 1
+	for _, ch := range chs {
+		size += len(ch.Name) + 1 + 16
+	}
+	attr("channels", "chlist", size)
+	for _, ch := range chs {
+		head.WriteString(ch.Name + "\x00")
+		u32(exrFloat)
+		head.Write([]byte{0, 0, 0, 0}) // pLinear and reserved
+		u32(1)                         // x sampling
+		u32(1)                         // y sampling
+	}
+	head.WriteByte(0)
+	attr("compression", "compression", 1)
+	head.WriteByte(0)
+	box("dataWindow")
+	box("displayWindow")
+	attr("lineOrder", "lineOrder", 1)
+	head.WriteByte(0) // increasing y
+	attr("pixelAspectRatio", "float", 4)
+	f32(1)
+	attr("screenWindowCenter", "v2f", 8)
+	f32(0)
+	f32(0)
+	attr("screenWindowWidth", "float", 4)
+	f32(1)
+	head.WriteByte(0)
+
+	// one scanline per chunk, each with its y and size
+	lineSize := width * 4 * len(chs)
+	table := make([]byte, 0, 8*height)
+	start := head.Len() + 8*height
+	for row := range height {
+		table = le.AppendUint64(table, uint64(start+row*(8+lineSize)))
+	}
+	if _, err := w.Write(head.Bytes()); err != nil {
+		return err
+	}
+	if _, err := w.Write(table); err != nil {
+		return err
+	}
+	line := make([]byte, 0, 8+lineSize)
+	for row := range height {
+		line = le.AppendUint32(line[:0], uint32(int32(img.Rect.Min.Y+row)))
+		line = le.AppendUint32(line, uint32(lineSize))
+		for _, ch := range chs {
+			for _, v := range ch.Pix[row*width : (row+1)*width] {
+				line = le.AppendUint32(line, math.Float32bits(v))
+			}
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errEXRTruncated = errors.New("truncated exr file")
+
+// exrReader reads the little endian values of an exr file, keeping the first error
+type exrReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *exrReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = errEXRTruncated
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *exrReader) u32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *exrReader) str() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.data[r.pos:], 0)
+	if end < 0 {
+		r.err = errEXRTruncated
+		return ""
+	}
+	s := string(r.data[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s
+}
+
+// ReadEXR reads uncompressed scanline OpenEXR files, with float, half or uint channels.
+// All channels are returned as float32.
+func ReadEXR(src io.Reader) (*EXRImage, error) {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	r := &exrReader{data: data}
+	if r.u32() != exrMagic {
+		return nil, errors.New("not an exr file")
+	}
+	version := r.u32()
+	if version&0xff != exrVersion || version&exrUnsupported != 0 {
+		return nil, fmt.Errorf("unsupported exr version %#x", version)
+	}
+
+	type channel struct {
+		name string
+		typ  uint32
+	}
+	var chs []channel
+	var rect image.Rectangle
+	compression := -1
+	for r.err == nil {
+		name := r.str()
+		if name == "" {
+			break
+		}
+		typ := r.str()
+		size := int(r.u32())
+		attr := &exrReader{data: r.bytes(size)}
+		switch {
+		case name == "channels" && typ == "chlist":
+			for {
+				chName := attr.str()
+				if chName == "" {
+					break
+				}
+				chType := attr.u32()
+				attr.bytes(4)
+				xs, ys := attr.u32(), attr.u32()
+				if xs != 1 || ys != 1 {
+					return nil, fmt.Errorf("exr channel %q is subsampled", chName)
+				}
+				if chType > exrFloat {
+					return nil, fmt.Errorf("exr channel %q has unknown type %d", chName, chType)
+				}
+				chs = append(chs, channel{chName, chType})
+			}
+		case name == "compression" && typ == "compression":
+			if b := attr.bytes(1); b != nil {
+				compression = int(b[0])
+			}
+		case name == "dataWindow" && typ == "box2i":
+			x0, y0 := int32(attr.u32()), int32(attr.u32())
+			x1, y1 := int32(attr.u32()), int32(attr.u32())
+			rect = image.Rect(int(x0), int(y0), int(x1)+1, int(y1)+1)
+		}
+		if attr.err != nil {
+			return nil, fmt.Errorf("exr attribute %q: %w", name, attr.err)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if compression != 0 {
+		return nil, fmt.Errorf("unsupported exr compression %d", compression)
+	}
+	if rect.Empty() || len(chs) == 0 {
+		return nil, errors.New("exr file without pixels")
+	}
+
+	width, height := rect.Dx(), rect.Dy()
+	lineSize := 0
+	for _, ch := range chs {
+		if ch.typ == exrHalf {
+			lineSize += 2 * width
+		} else {
+			lineSize += 4 * width
+		}
+	}
+	// NOTE(@lberg): check the size before allocating what a corrupt header asks for
+	if int64(lineSize)*int64(height) > int64(len(data)) {
+		return nil, errEXRTruncated
+	}
+	img := &EXRImage{Rect: rect, Channels: make([]EXRChannel, len(chs))}
+	for idx, ch := range chs {
+		img.Channels[idx] = EXRChannel{ch.name, make([]float32, width*height)}
+	}
+	offsets := make([]int, height)
+	for idx := range offsets {
+		lo, hi := r.u32(), r.u32()
+		offsets[idx] = int(uint64(hi)<<32 | uint64(lo))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	for _, offset := range offsets {
+		if offset < 0 || offset > len(data) {
+			return nil, errEXRTruncated
+		}
+		chunk := &exrReader{data: data, pos: offset}
+		row := int(int32(chunk.u32())) - rect.Min.Y
+		size := int(chunk.u32())
+		if chunk.err != nil {
+			return nil, chunk.err
+		}
+		if size != lineSize {
+			return nil, fmt.Errorf("exr scanline has %d bytes, want %d", size, lineSize)
+		}
+		if row < 0 || row >= height {
+			return nil, fmt.Errorf("exr scanline %d outside of %v", row+rect.Min.Y, rect)
+		}
+		for idx, ch := range chs {
+			pix := img.Channels[idx].Pix[row*width : (row+1)*width]
+			for x := range pix {
+				switch ch.typ {
+				case exrHalf:
+					if b := chunk.bytes(2); b != nil {
+						pix[x] = halfToFloat(binary.LittleEndian.Uint16(b))
+					}
+				case exrUint:
+					pix[x] = float32(chunk.u32())
+				default:
+					pix[x] = math.Float32frombits(chunk.u32())
+				}
+			}
+		}
+		if chunk.err != nil {
+			return nil, chunk.err
+		}
+	}
+	return img, nil
+}
+
+// halfToFloat decodes an IEEE 754 half precision float
+func halfToFloat(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h) & 0x3ff
+	switch {
+	case exp == 0x1f:
+		// infinities and NaNs
+		return math.Float32frombits(sign | 0xff<<23 | mant<<13)
+	case exp != 0:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+	case mant == 0:
+		return math.Float32frombits(sign)
+	}
+	// subnormals are normal floats
+	v := float32(mant) / (1 << 24)
+	if sign != 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEXRRoundTrip(t *testing.T) {
+	engine := NewEngine()
+	engine.RepositionCamera(func(f Frame) Frame {
+		return f.Move(I.Mul(-5))
+	})
+	cube, err := NewCube(1, 1, 1)
+	require.NoError(t, err)
+	engine.Add(&cube)
+
+	var aovs AOVs
+	fb, err := engine.RenderHDR(context.Background(), 33, 1.5, WithAOVs(&aovs, AllAOVs))
+	require.NoError(t, err)
+	img := NewEXRImage(fb, &aovs)
+	require.Len(t, img.Channels, 13)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteEXR(&buf, img))
+	got, err := ReadEXR(&buf)
+	require.NoError(t, err)
+	require.Equal(t, img.Rect, got.Rect)
+	require.Len(t, got.Channels, len(img.Channels))
+	for _, ch := range img.Channels {
+		require.Equal(t, ch.Pix, got.Channel(ch.Name), ch.Name)
+	}
+	require.Equal(t, fb.Pix, got.Framebuffer().Pix)
+	require.True(t, math.IsInf(float64(got.Channel("Z")[0]), 1))
+
+	// data windows do not have to start at the origin
+	moved := &EXRImage{
+		Rect:     image.Rect(-2, 3, 1, 5),
+		Channels: []EXRChannel{{"Y", []float32{1, 2, 3, 4, 5, 6}}},
+	}
+	buf.Reset()
+	require.NoError(t, WriteEXR(&buf, moved))
+	data := buf.Bytes()
+	got, err = ReadEXR(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, moved, got)
+
+	// truncated files are errors, not panics
+	for _, n := range []int{0, 3, 8, 40, len(data) - 1} {
+		_, err := ReadEXR(bytes.NewReader(data[:n]))
+		require.Error(t, err, n)
+	}
+
+	// so are offsets pointing past the data, for images away from the origin too
+	buf.Reset()
+	require.NoError(t, WriteEXR(&buf, &EXRImage{
+		Rect:     image.Rect(0, 5, 2, 7),
+		Channels: []EXRChannel{{"Y", []float32{1, 2, 3, 4}}},
+	}))
+	data = buf.Bytes()
+	// the offset table is right before the first scanline
+	table := -1
+	for pos := 0; pos+8 <= len(data); pos++ {
+		if binary.LittleEndian.Uint64(data[pos:]) == uint64(pos+2*8) {
+			table = pos
+			break
+		}
+	}
+	require.NotEqual(t, -1, table)
+	for _, offset := range []int{len(data), len(data) - 4} {
+		corrupt := bytes.Clone(data)
+		binary.LittleEndian.PutUint64(corrupt[table:], uint64(offset))
+		_, err = ReadEXR(bytes.NewReader(corrupt))
+		require.ErrorIs(t, err, errEXRTruncated, offset)
+	}
+
+	require.Error(t, WriteEXR(&buf, &EXRImage{
+		Rect:     image.Rect(0, 0, 2, 2),
+		Channels: []EXRChannel{{"R", []float32{1}}},
+	}))
+}
+
+func TestHalfToFloat(t *testing.T) {
+	for h, want := range map[uint16]float32{
+		0x0000: 0,
+		0x3c00: 1,
+		0xc000: -2,
+		0x3555: 0.333251953125,
+		0x7bff: 65504,
+		0x0001: 1. / (1 << 24),
+	} {
+		require.Equal(t, want, halfToFloat(h), "%#x", h)
+	}
+	require.True(t, math.IsInf(float64(halfToFloat(0x7c00)), 1))
+	require.True(t, math.IsNaN(float64(halfToFloat(0x7e00))))
+}
@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// LinearColor is a color in linear RGB with the channels premultiplied by alpha.
+// Channels are not bounded to [0, 1] so lights and accumulation have headroom.
+type LinearColor struct {
+	R, G, B, A float32
+}
+
+func (c LinearColor) Add(o LinearColor) LinearColor {
+	return LinearColor{c.R + o.R, c.G + o.G, c.B + o.B, c.A + o.A}
+}
+
+func (c LinearColor) Mul(s float32) LinearColor {
+	return LinearColor{c.R * s, c.G * s, c.B * s, c.A * s}
+}
+
+// srgbToLinear maps an 8 bit sRGB value to linear
+var srgbToLinear = func() [256]float32 {
+	var lut [256]float32
+	for idx := range lut {
+		v := float64(idx) / 255
+		if v <= 0.04045 {
+			lut[idx] = float32(v / 12.92)
+		} else {
+			lut[idx] = float32(math.Pow((v+0.055)/1.055, 2.4))
+		}
+	}
+	return lut
+}()
+
+// linearToSRGB maps linear values in [0, 1] to 8 bit sRGB, indexed by value*(len-1)
+var linearToSRGB = func() []uint8 {
+	// NOTE(@lberg): fine enough for the darkest sRGB steps to round trip
+	lut := make([]uint8, 1<<16)
+	for idx := range lut {
+		v := float64(idx) / float64(len(lut)-1)
+		if v <= 0.0031308 {
+			v *= 12.92
+		} else {
+			v = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+		lut[idx] = uint8(math.Round(v * 255))
+	}
+	return lut
+}()
+
+// LinearFromColor decodes an sRGB color, as all the color.Color of the image package are
+func LinearFromColor(c color.Color) LinearColor {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	a := float32(n.A) / 255
+	return LinearColor{srgbToLinear[n.R] * a, srgbToLinear[n.G] * a, srgbToLinear[n.B] * a, a}
+}
+
+// encodeSRGB maps a linear value to 8 bit sRGB, clamping it to [0, 1], NaN is 0
+func encodeSRGB(v float32) uint8 {
+	// NOTE(@lberg): min and max keep NaN, which would index out of the table
+	if !(v > 0) {
+		return linearToSRGB[0]
+	}
+	v = min(1, v)
+	return linearToSRGB[int(v*float32(len(linearToSRGB)-1)+0.5)]
+}
+
+// maxExposure bounds the exposure so that 2^stops stays a normal float32
+const maxExposure = 126
+
+// clampExposure bounds stops to ±maxExposure, NaN is 0
+func clampExposure(stops float64) float64 {
+	if stops != stops {
+		return 0
+	}
+	return max(-maxExposure, min(maxExposure, stops))
+}
+
+// Framebuffer is an image of linear colors
+type Framebuffer struct {
+	Buffer[LinearColor]
+}
+
+func NewFramebuffer(r image.Rectangle) *Framebuffer {
+	return &Framebuffer{*NewBuffer[LinearColor](r)}
+}
+
+// Resolve maps the framebuffer to a displayable image.
+// Colors are scaled by the exposure, in stops, tone mapped and then sRGB encoded.
+// The exposure is clamped like WithExposure does.
+func (fb *Framebuffer) Resolve(tm ToneMapping, exposure float64) *image.RGBA {
+	img := image.NewRGBA(fb.Rect)
+	fb.ResolveInto(img, fb.Rect, tm, exposure)
+	return img
+}
+
+// ResolveInto is Resolve limited to the pixels in r, written to dst
+func (fb *Framebuffer) ResolveInto(dst *image.RGBA, r image.Rectangle, tm ToneMapping, exposure float64) {
+	r = r.Intersect(fb.Rect).Intersect(dst.Rect)
+	scale := float32(math.Exp2(clampExposure(exposure)))
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := fb.Pix[fb.offset(x, y)]
+			if !(c.A > 0) {
+				dst.SetRGBA(x, y, color.RGBA{})
+				continue
+			}
+			// NOTE(@lberg): tone mapping and encoding work on straight colors,
+			// the result is premultiplied again for image.RGBA
+			a := min(c.A, 1)
+			straight := c.Mul(scale / c.A)
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(float32(encodeSRGB(tm.Map(straight.R)))*a + 0.5),
+				G: uint8(float32(encodeSRGB(tm.Map(straight.G)))*a + 0.5),
+				B: uint8(float32(encodeSRGB(tm.Map(straight.B)))*a + 0.5),
+				A: uint8(a*255 + 0.5),
+			})
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// Instance draws a shared geometry with its own transform and color.
+// The geometry is referenced, never copied, so instances are cheap
+// and the geometry must not change while they are in use.
+type Instance struct {
+	geometry Renderable
+	// transform maps the geometry coordinates to the instance ones, inverse goes back
+	transform, inverse Matrix4
+	// bounds are the geometry bounds, slightly larger to keep the edges
+	bounds *Box
+	color  color.Color
+	IDGen
+}
+
+type instanceOption func(*Instance)
+
+// WithInstanceColor replaces the base color of the geometry, edge colors are kept
+func WithInstanceColor(c color.Color) instanceOption {
+	return func(in *Instance) {
+		in.color = c
+	}
+}
+
+// NewInstance places geometry with the transform m, which can scale and shear it
+// but must be invertible
+func NewInstance(geometry Renderable, m Matrix4, opts ...instanceOption) (Instance, error) {
+	inv, ok := m.Inverse()
+	if !ok {
+		return Instance{}, fmt.Errorf("degenerate instance transform")
+	}
+	in := Instance{geometry: geometry, transform: m, inverse: inv}
+	// NOTE(@lberg): unbounded geometries, like SDFs without bounds, are tested on every line
+	if br, ok := geometry.(Bounded); ok && !br.Bounds().Empty() {
+		b := br.Bounds()
+		// NOTE(@lberg): edges are detected a bit outside of the faces
+		pad := Vector{1, 1, 1}.Mul(3e-3*b.Diagonal() + Eps)
+		b = Box{b.Min.Sub(pad), b.Max.Add(pad)}
+		in.bounds = &b
+	}
+	for _, op := range opts {
+		op(&in)
+	}
+	return in, nil
+}
+
+func (in *Instance) Geometry() Renderable {
+	return in.geometry
+}
+
+func (in *Instance) Transform() Matrix4 {
+	return in.transform
+}
+
+func (in *Instance) Intersect(l *Line) *Intersection {
+	local, ok := in.local(l)
+	if !ok {
+		return nil
+	}
+	inter := in.geometry.Intersect(&local)
+	if inter == nil {
+		return nil
+	}
+	in.toWorld(inter, l)
+	return inter
+}
+
+// IsSolid reports whether the geometry is a Solid, which Intervals needs
+func (in *Instance) IsSolid() bool {
+	_, ok := in.geometry.(Solid)
+	return ok
+}
+
+// Intervals returns the intervals of the geometry, nil if it is not a Solid
+func (in *Instance) Intervals(l *Line) []Interval {
+	s, ok := in.geometry.(Solid)
+	if !ok {
+		return nil
+	}
+	local, ok := in.local(l)
+	if !ok {
+		return nil
+	}
+	ivs := s.Intervals(&local)
+	for _, iv := range ivs {
+		in.toWorld(iv.In, l)
+		in.toWorld(iv.Out, l)
+	}
+	return ivs
+}
+
+// Triangles returns the triangles of the geometry transformed, nil if it is not a Mesh
+func (in *Instance) Triangles() []Triangle {
+	m, ok := in.geometry.(Mesh)
+	if !ok {
+		return nil
+	}
+	tris := m.Triangles()
+	for idx := range tris {
+		tris[idx] = tris[idx].Transform(in.transform)
+		if in.color != nil {
+			recolorTriangle(&tris[idx], in.geometry, in.color)
+		}
+	}
+	return tris
+}
+
+// local maps l in geometry coordinates, false if it misses the bounds
+func (in *Instance) local(l *Line) (Line, bool) {
+	local := NewLine(in.inverse.Apply(l.P), in.inverse.ApplyDir(l.Dir))
+	if in.bounds != nil && !in.bounds.Hit(&local) {
+		return Line{}, false
+	}
+	return local, true
+}
+
+// toWorld maps an intersection with the geometry back to the instance coordinates,
+// l is the line in these coordinates
+func (in *Instance) toWorld(inter *Intersection, l *Line) {
+	// NOTE(@lberg): transforms may scale, so distances are measured again
+	// once the point is back in instance coordinates
+	behind := inter.SignedDist < 0
+	inter.IntPoint = in.transform.Apply(inter.IntPoint)
+	inter.SignedDist = inter.IntPoint.Sub(l.P).Norm()
+	if behind {
+		inter.SignedDist *= -1
+	}
+	// normals follow the inverse transpose
+	n := inter.Normal
+	inter.Normal = Vector{
+		in.inverse[0][0]*n.X + in.inverse[1][0]*n.Y + in.inverse[2][0]*n.Z,
+		in.inverse[0][1]*n.X + in.inverse[1][1]*n.Y + in.inverse[2][1]*n.Z,
+		in.inverse[0][2]*n.X + in.inverse[1][2]*n.Y + in.inverse[2][2]*n.Z,
+	}.Normalize()
+	if in.color != nil {
+		recolor(inter, in.geometry, in.color)
+	}
+}
+
+// Color returns the instance color, the geometry one if not overridden
+func (in *Instance) Color() color.Color {
+	if in.color != nil {
+		return in.color
+	}
+	if c, ok := in.geometry.(Colored); ok {
+		return c.Color()
+	}
+	return nil
+}
+
+// Bounds returns the box of the transformed geometry, empty if the geometry is not Bounded
+func (in *Instance) Bounds() Box {
+	if in.bounds == nil {
+		return EmptyBox()
+	}
+	return in.geometry.(Bounded).Bounds().Map(in.transform.Apply)
+}
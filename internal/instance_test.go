@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"context"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstance(t *testing.T) {
+	cube, err := NewCube(1, 1, 1)
+	require.NoError(t, err)
+	_, err = NewInstance(&cube, Scaling(Vector{1, 0, 1}))
+	require.Error(t, err)
+
+	green := color.RGBA{0, 255, 0, 255}
+	m := Compose(Vector{0, 0, 5}, QuaternionFromAxisAngle(K, math.Pi/4), Vector{2, 2, 4})
+	in, err := NewInstance(&cube, m, WithInstanceColor(green))
+	require.NoError(t, err)
+	require.NotEqual(t, cube.ID(), in.ID())
+	require.Equal(t, color.Color(green), in.Color())
+
+	// distances and normals are in instance coordinates, even when scaled
+	l := NewLine(Vector{0, 0, 10}, K.Neg())
+	inter := in.Intersect(&l)
+	require.NotNil(t, inter)
+	require.InDelta(t, 3, inter.SignedDist, 1e-9)
+	require.InDeltaSlice(t, sl(Vector{0, 0, 7}), sl(inter.IntPoint), 1e-9)
+	require.InDeltaSlice(t, sl(K), sl(inter.Normal), 1e-9)
+	require.Equal(t, color.Color(green), inter.Color)
+	// a sheared side keeps a unit normal facing the ray
+	side := NewLine(Vector{-10, 0, 5}, I)
+	inter = in.Intersect(&side)
+	require.NotNil(t, inter)
+	require.InDelta(t, 1, inter.Normal.Norm(), 1e-9)
+	require.Less(t, inter.Normal.Dot(side.Dir), 0.)
+	require.InDelta(t, 10-math.Sqrt2, inter.SignedDist, 1e-9)
+
+	// edges keep their color
+	corner := NewLine(Vector{math.Sqrt2, 0, 10}, K.Neg())
+	inter = in.Intersect(&corner)
+	require.NotNil(t, inter)
+	require.NotEqual(t, inside, inter.Where)
+	require.Equal(t, color.Color(color.Black), inter.Color)
+
+	miss := NewLine(Vector{3, 3, 10}, K.Neg())
+	require.Nil(t, in.Intersect(&miss))
+
+	b := in.Bounds()
+	require.InDeltaSlice(t, sl(Vector{-math.Sqrt2, -math.Sqrt2, 3}), sl(b.Min), 1e-9)
+	require.InDeltaSlice(t, sl(Vector{math.Sqrt2, math.Sqrt2, 7}), sl(b.Max), 1e-9)
+}
+
+func TestInstanceRender(t *testing.T) {
+	// instances render like copies of the geometry
+	cube, err := NewCube(1, 1, 1)
+	require.NoError(t, err)
+	copies, instances := NewEngine(), NewEngine()
+	for idx := range 5 {
+		m := Compose(J.Mul(float64(idx-2)*1.5), QuaternionFromAxisAngle(Vector{1, 1, 1}, Radian(idx)), Vector{1, 1, 1})
+		c := cube.Transform(m)
+		copies.Add(&c)
+		in, err := NewInstance(&cube, m)
+		require.NoError(t, err)
+		instances.Add(&in)
+	}
+	for _, e := range []*Engine{copies, instances} {
+		e.RepositionCamera(func(Frame) Frame { return LookAt(Vector{-6, 0, 1}, Zero, K) })
+	}
+	want, err := copies.Render(context.Background(), 64, 2)
+	require.NoError(t, err)
+	got, err := instances.Render(context.Background(), 64, 2)
+	require.NoError(t, err)
+	diff := 0
+	for idx := range want.Pix {
+		if want.Pix[idx] != got.Pix[idx] {
+			diff++
+		}
+	}
+	// NOTE(@lberg): rounding may flip a few pixels on the edges
+	require.Less(t, diff, len(want.Pix)/100)
+}
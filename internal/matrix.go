@@ -0,0 +1,150 @@
+package internal
+
+import "math"
+
+// Matrix4 is an affine transform in homogeneous coordinates, indexed by row then column.
+// Points are column vectors, so the last column holds the translation
+// and the last row is always 0, 0, 0, 1.
+type Matrix4 [4][4]float64
+
+var IdentityMatrix = Matrix4{
+	{1, 0, 0, 0},
+	{0, 1, 0, 0},
+	{0, 0, 1, 0},
+	{0, 0, 0, 1},
+}
+
+// Translation returns the transform moving points by v
+func Translation(v Vector) Matrix4 {
+	m := IdentityMatrix
+	m[0][3], m[1][3], m[2][3] = v.X, v.Y, v.Z
+	return m
+}
+
+// Scaling returns the transform scaling each axis by the matching component of s
+func Scaling(s Vector) Matrix4 {
+	m := IdentityMatrix
+	m[0][0], m[1][1], m[2][2] = s.X, s.Y, s.Z
+	return m
+}
+
+// RotationMatrix returns the transform rotating around the origin by q
+func RotationMatrix(q Quaternion) Matrix4 {
+	return MatrixFromFrame(q.Frame(Zero))
+}
+
+// RotationAround returns the transform rotating by angle around axis, like Vector.Rotate
+func RotationAround(axis Line, angle Radian) Matrix4 {
+	r := RotationMatrix(QuaternionFromAxisAngle(axis.Dir, angle))
+	return Translation(axis.P).Mul(r).Mul(Translation(axis.P.Neg()))
+}
+
+// Compose returns the transform scaling by s, then rotating by r and then translating by t
+func Compose(t Vector, r Quaternion, s Vector) Matrix4 {
+	m := RotationMatrix(r)
+	for row := range 3 {
+		m[row][0] *= s.X
+		m[row][1] *= s.Y
+		m[row][2] *= s.Z
+	}
+	m[0][3], m[1][3], m[2][3] = t.X, t.Y, t.Z
+	return m
+}
+
+// MatrixFromFrame returns the transform mapping coordinates in f to world ones, like Frame.ToWorld
+func MatrixFromFrame(f Frame) Matrix4 {
+	return Matrix4{
+		{f.I.X, f.J.X, f.K.X, f.P.X},
+		{f.I.Y, f.J.Y, f.K.Y, f.P.Y},
+		{f.I.Z, f.J.Z, f.K.Z, f.P.Z},
+		{0, 0, 0, 1},
+	}
+}
+
+// Frame returns the frame of the translation and rotation of m, scaling is dropped
+func (m Matrix4) Frame() Frame {
+	t, r, _ := m.Decompose()
+	return r.Frame(t)
+}
+
+// Mul composes the transforms, o is applied first
+func (m Matrix4) Mul(o Matrix4) Matrix4 {
+	var res Matrix4
+	for row := range 4 {
+		for col := range 4 {
+			for idx := range 4 {
+				res[row][col] += m[row][idx] * o[idx][col]
+			}
+		}
+	}
+	return res
+}
+
+// Apply transforms the point p
+func (m Matrix4) Apply(p Vector) Vector {
+	return Vector{
+		m[0][0]*p.X + m[0][1]*p.Y + m[0][2]*p.Z + m[0][3],
+		m[1][0]*p.X + m[1][1]*p.Y + m[1][2]*p.Z + m[1][3],
+		m[2][0]*p.X + m[2][1]*p.Y + m[2][2]*p.Z + m[2][3],
+	}
+}
+
+// ApplyDir transforms the direction v, which is not affected by translations.
+// Normals need the inverse transpose instead, unless m is rigid.
+func (m Matrix4) ApplyDir(v Vector) Vector {
+	return Vector{
+		m[0][0]*v.X + m[0][1]*v.Y + m[0][2]*v.Z,
+		m[1][0]*v.X + m[1][1]*v.Y + m[1][2]*v.Z,
+		m[2][0]*v.X + m[2][1]*v.Y + m[2][2]*v.Z,
+	}
+}
+
+// det3 is the determinant of the linear part
+func (m Matrix4) det3() float64 {
+	return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+}
+
+// Inverse returns the inverse transform, false if m collapses space on a plane or less
+func (m Matrix4) Inverse() (Matrix4, bool) {
+	det := m.det3()
+	if math.Abs(det) < Eps {
+		return Matrix4{}, false
+	}
+	// NOTE(@lberg): the inverse of the linear part is its adjugate over the determinant,
+	// the translation is then undone with it
+	inv := IdentityMatrix
+	for row := range 3 {
+		for col := range 3 {
+			r0, r1 := (col+1)%3, (col+2)%3
+			c0, c1 := (row+1)%3, (row+2)%3
+			inv[row][col] = (m[r0][c0]*m[r1][c1] - m[r0][c1]*m[r1][c0]) / det
+		}
+	}
+	t := inv.ApplyDir(Vector{m[0][3], m[1][3], m[2][3]})
+	inv[0][3], inv[1][3], inv[2][3] = -t.X, -t.Y, -t.Z
+	return inv, true
+}
+
+// Decompose splits m in the translation t, rotation r and scale s given to Compose.
+// Shears are lost and mirroring shows up as a negative X scale.
+func (m Matrix4) Decompose() (t Vector, r Quaternion, s Vector) {
+	t = Vector{m[0][3], m[1][3], m[2][3]}
+	cols := [3]Vector{}
+	for col := range 3 {
+		cols[col] = Vector{m[0][col], m[1][col], m[2][col]}
+	}
+	s = Vector{cols[0].Norm(), cols[1].Norm(), cols[2].Norm()}
+	if m.det3() < 0 {
+		s.X = -s.X
+	}
+	if s.X == 0 || s.Y == 0 || s.Z == 0 {
+		return t, IdentityQuaternion, s
+	}
+	// orthonormalize what is left, in case of shears
+	i := cols[0].Mul(1 / s.X)
+	k := i.Cross(cols[1]).Normalize()
+	r = QuaternionFromFrame(Frame{i, k.Cross(i), k, Zero})
+	return t, r, s
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func requireMatrixInDelta(t *testing.T, expected, actual Matrix4) {
+	t.Helper()
+	for row := range 4 {
+		require.InDeltaSlice(t, expected[row][:], actual[row][:], 1e-9, "row %d", row)
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	tr := Vector{1, -2, 3}
+	rot := QuaternionFromAxisAngle(Vector{1, 1, 0}, 0.8)
+	scale := Vector{2, 0.5, 3}
+	m := Compose(tr, rot, scale)
+	p := Vector{0.3, 0.7, -1.1}
+	require.InDeltaSlice(t, sl(rot.Rotate(Vector{0.6, 0.35, -3.3}).Add(tr)), sl(m.Apply(p)), 1e-9)
+	require.Equal(t, m, Translation(tr).Mul(RotationMatrix(rot)).Mul(Scaling(scale)))
+
+	inv, ok := m.Inverse()
+	require.True(t, ok)
+	requireMatrixInDelta(t, IdentityMatrix, m.Mul(inv))
+	requireMatrixInDelta(t, IdentityMatrix, inv.Mul(m))
+	_, ok = Scaling(Vector{1, 0, 1}).Inverse()
+	require.False(t, ok)
+
+	gotT, gotR, gotS := m.Decompose()
+	require.InDeltaSlice(t, sl(tr), sl(gotT), 1e-9)
+	require.InDeltaSlice(t, sl(scale), sl(gotS), 1e-9)
+	require.InDelta(t, 1, math.Abs(rot.Dot(gotR)), 1e-9)
+	// mirrors are kept
+	_, _, gotS = Scaling(Vector{1, -1, 1}).Decompose()
+	require.InDeltaSlice(t, sl(Vector{-1, 1, 1}), sl(gotS), 1e-9)
+
+	f := LookAt(Vector{-4, -3, 2}, Zero, K)
+	fm := MatrixFromFrame(f)
+	require.InDeltaSlice(t, sl(f.ToWorld(p)), sl(fm.Apply(p)), 1e-9)
+	back := fm.Frame()
+	for _, pair := range [][2]Vector{{f.I, back.I}, {f.J, back.J}, {f.K, back.K}, {f.P, back.P}} {
+		require.InDeltaSlice(t, sl(pair[0]), sl(pair[1]), 1e-9)
+	}
+	require.InDeltaSlice(t, sl(m.Apply(fm.Apply(p))), sl(m.Mul(fm).Apply(p)), 1e-9)
+
+	axis := Line{Vector{1, 2, 3}, J}
+	require.InDeltaSlice(t, sl(p.Rotate(axis, 1.2)), sl(RotationAround(axis, 1.2).Apply(p)), 1e-9)
+}
+
+func TestTransformPrimitives(t *testing.T) {
+	cube, err := NewCube(1, 1, 1)
+	require.NoError(t, err)
+	m := Compose(Vector{0, 0, 5}, QuaternionFromAxisAngle(K, math.Pi/4), Vector{2, 2, 2})
+	moved := cube.Transform(m)
+	require.NotEqual(t, cube.ID(), moved.ID())
+
+	b := moved.Bounds()
+	half := math.Sqrt2
+	require.InDeltaSlice(t, sl(Vector{-half, -half, 4}), sl(b.Min), 1e-9)
+	require.InDeltaSlice(t, sl(Vector{half, half, 6}), sl(b.Max), 1e-9)
+	// the original is untouched
+	require.InDeltaSlice(t, sl(Vector{-0.5, -0.5, -0.5}), sl(cube.Bounds().Min), 1e-9)
+
+	l := NewLine(Vector{0, 0, 10}, K.Neg())
+	inter := moved.Intersect(&l)
+	require.NotNil(t, inter)
+	require.InDelta(t, 4, inter.SignedDist, 1e-9)
+	require.InDeltaSlice(t, sl(K), sl(inter.Normal), 1e-9)
+}
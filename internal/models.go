@@ -8,25 +8,58 @@ import (
 
 const Eps = 1e-12
 
-type intersectionType int
+// IntersectionType tells where a line hit a surface, for triangulated
+// surfaces it distinguishes edges and corners from the inside of faces
+type IntersectionType int
 
 const (
-	inside intersectionType = iota + 1
+	inside IntersectionType = iota + 1
 	corner
 	edge
 )
 
+func (it IntersectionType) String() string {
+	switch it {
+	case inside:
+		return "inside"
+	case corner:
+		return "corner"
+	case edge:
+		return "edge"
+	default:
+		return "none"
+	}
+}
+
 type Intersection struct {
 	IntPoint   Vector
 	SignedDist float64
-	Color      color.Color
-	Where      intersectionType
+	// Normal is the unit normal of the surface, facing the origin of the line
+	Normal Vector
+	Color  color.Color
+	Where  IntersectionType
+	// base is the color of the surface around edges, Color elsewhere when nil
+	base color.Color
+}
+
+// surfaceColor is the color of the surface, ignoring edge colors
+func (inter *Intersection) surfaceColor() color.Color {
+	if inter.base != nil {
+		return inter.base
+	}
+	return inter.Color
 }
 
 type Renderable interface {
 	Intersect(l *Line) *Intersection
 	ID() string
 }
+
+// Colored is implemented by renderables with a base color
+type Colored interface {
+	Color() color.Color
+}
+
 type IDGen struct {
 	id *string
 }
@@ -0,0 +1,19 @@
+package internal
+
+// Node groups renderables, which are placed relative to it,
+// so moving a node moves all its descendants.
+// Nodes have no geometry, they only make sense added to an Engine.
+type Node struct {
+	IDGen
+	// Frame is the initial placement of the node relative to its parent
+	Frame    Frame
+	Children []Renderable
+}
+
+func NewNode(f Frame, children ...Renderable) *Node {
+	return &Node{Frame: f, Children: children}
+}
+
+func (n *Node) Intersect(*Line) *Intersection {
+	return nil
+}
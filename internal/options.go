@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"image"
+	"runtime"
+)
+
+type renderConfig struct {
+	workers  int
+	tileSize int
+	order    TileOrder
+	// progressive renders start with one pixel every coarsest
+	coarsest int
+	progress ProgressFunc
+	// exposure is in stops
+	exposure    float64
+	toneMapping ToneMapping
+	// aovs receives the auxiliary buffers listed in aovKinds
+	aovs     *AOVs
+	aovKinds AOV
+	backend  Backend
+	// wireframe draws the edges as lines when not nil
+	wireframe *Wireframe
+}
+
+// ProgressFunc receives the partial image after each pass of a progressive render,
+// pass goes from 1 to passes. The image is written again by the following passes
+// so it has to be copied to be kept. Returning false stops the render,
+// which then returns the image as it is.
+type ProgressFunc func(img *image.RGBA, pass, passes int) bool
+
+// RenderOption configures a single render
+type RenderOption func(*renderConfig)
+
+// WithWorkers sets the number of tiles rendered in parallel, GOMAXPROCS by default
+func WithWorkers(n int) RenderOption {
+	return func(rc *renderConfig) {
+		rc.workers = max(n, 1)
+	}
+}
+
+// WithTileSize sets the side of the square tiles in pixels, 32 by default.
+// Progressive renders round it up to a multiple of their coarsest step.
+func WithTileSize(n int) RenderOption {
+	return func(rc *renderConfig) {
+		rc.tileSize = max(n, 1)
+	}
+}
+
+// WithTileOrder sets the order tiles are rendered in, CenterOutOrder by default
+func WithTileOrder(o TileOrder) RenderOption {
+	return func(rc *renderConfig) {
+		rc.order = o
+	}
+}
+
+// WithExposure scales the linear colors by 2^stops before tone mapping.
+// Stops are clamped to [-126, 126], NaN is 0.
+func WithExposure(stops float64) RenderOption {
+	return func(rc *renderConfig) {
+		rc.exposure = clampExposure(stops)
+	}
+}
+
+// WithToneMapping sets how linear colors are compressed for display, ClampToneMapping by default
+func WithToneMapping(tm ToneMapping) RenderOption {
+	return func(rc *renderConfig) {
+		rc.toneMapping = tm
+	}
+}
+
+// WithBackend sets how the visible surfaces are found, RayTracingBackend by default
+func WithBackend(b Backend) RenderOption {
+	return func(rc *renderConfig) {
+		rc.backend = b
+	}
+}
+
+// WithProgress renders in passes of increasing resolution calling fn after each of them.
+// The first pass renders one pixel every coarsest (rounded down to a power of two)
+// in both directions and fills the gaps with it, each following pass halves the step.
+func WithProgress(coarsest int, fn ProgressFunc) RenderOption {
+	return func(rc *renderConfig) {
+		rc.coarsest = max(coarsest, 1)
+		rc.progress = fn
+	}
+}
+
+// steps returns the pixel step of every pass, from the coarsest to 1
+func (rc *renderConfig) steps() []int {
+	step := 1
+	for step*2 <= rc.coarsest {
+		step *= 2
+	}
+	var steps []int
+	for ; step >= 1; step /= 2 {
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func newRenderConfig(opts ...RenderOption) renderConfig {
+	rc := renderConfig{
+		workers:  runtime.GOMAXPROCS(0),
+		tileSize: 32,
+		order:    CenterOutOrder,
+		coarsest: 1,
+	}
+	for _, op := range opts {
+		op(&rc)
+	}
+	// NOTE(@lberg): passes sample on a grid of their step, tiles not aligned
+	// on the coarsest one would start without a sample in their corner
+	coarsest := rc.steps()[0]
+	rc.tileSize = (rc.tileSize + coarsest - 1) / coarsest * coarsest
+	return rc
+}
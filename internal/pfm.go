@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+)
+
+// WritePFM writes the colors of fb as a little endian color PFM file.
+// The format has no alpha, colors are written as they are so premultiplied.
+func WritePFM(w io.Writer, fb *Framebuffer) error {
+	width, height := fb.Rect.Dx(), fb.Rect.Dy()
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("empty pfm image %v", fb.Rect)
+	}
+	bw := bufio.NewWriter(w)
+	// NOTE(@lberg): a negative scale means little endian
+	fmt.Fprintf(bw, "PF\n%d %d\n-1.0\n", width, height)
+	line := make([]byte, 0, 12*width)
+	// rows go from the bottom to the top
+	for y := fb.Rect.Max.Y - 1; y >= fb.Rect.Min.Y; y-- {
+		line = line[:0]
+		for x := fb.Rect.Min.X; x < fb.Rect.Max.X; x++ {
+			c := fb.Pix[fb.offset(x, y)]
+			for _, v := range []float32{c.R, c.G, c.B} {
+				line = binary.LittleEndian.AppendUint32(line, math.Float32bits(v))
+			}
+		}
+		bw.Write(line)
+	}
+	return bw.Flush()
+}
+
+// ReadPFM reads color (PF) and greyscale (Pf) PFM files of either endianness,
+// grey values are copied to all channels
+func ReadPFM(src io.Reader) (*Framebuffer, error) {
+	r := bufio.NewReader(src)
+	var magic string
+	var width, height int
+	var scale float64
+	if _, err := fmt.Fscan(r, &magic, &width, &height, &scale); err != nil {
+		return nil, fmt.Errorf("pfm header: %w", err)
+	}
+	// a single whitespace separates the header from the data
+	if _, err := r.ReadByte(); err != nil {
+		return nil, fmt.Errorf("pfm header: %w", err)
+	}
+	var channels int
+	switch magic {
+	case "PF":
+		channels = 3
+	case "Pf":
+		channels = 1
+	default:
+		return nil, errors.New("not a pfm file")
+	}
+	if width <= 0 || height <= 0 || width > 1<<20 || height > 1<<20 || scale == 0 {
+		return nil, fmt.Errorf("invalid pfm header %dx%d scale %v", width, height, scale)
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if scale < 0 {
+		order = binary.LittleEndian
+	}
+
+	fb := NewFramebuffer(image.Rect(0, 0, width, height))
+	line := make([]byte, 4*channels*width)
+	for y := height - 1; y >= 0; y-- {
+		if _, err := io.ReadFull(r, line); err != nil {
+			return nil, fmt.Errorf("pfm row %d: %w", y, err)
+		}
+		for x := range width {
+			v := func(c int) float32 {
+				return math.Float32frombits(order.Uint32(line[4*(x*channels+c):]))
+			}
+			if channels == 1 {
+				fb.Pix[fb.offset(x, y)] = LinearColor{v(0), v(0), v(0), 1}
+			} else {
+				fb.Pix[fb.offset(x, y)] = LinearColor{v(0), v(1), v(2), 1}
+			}
+		}
+	}
+	return fb, nil
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"cmp"
 	"image/color"
 	"slices"
 )
@@ -61,17 +62,18 @@ func NewQuad(p0, p1, p2, p3 Vector, opts ...quadOption) (Quad, error) {
 }
 
 func (q Quad) Move(v Vector) Quad {
-	newQ := q
-	newQ.t1 = newQ.t1.Move(v)
-	newQ.t2 = newQ.t2.Move(v)
-	newQ.IDGen = IDGen{}
-	return newQ
+	return q.Transform(Translation(v))
 }
 
 func (q Quad) Rotate(axis Line, angle Radian) Quad {
+	return q.Transform(RotationAround(axis, angle))
+}
+
+// Transform returns a copy of the quad with the vertices transformed by m
+func (q Quad) Transform(m Matrix4) Quad {
 	newQ := q
-	newQ.t1 = newQ.t1.Rotate(axis, angle)
-	newQ.t2 = newQ.t2.Rotate(axis, angle)
+	newQ.t1 = newQ.t1.Transform(m)
+	newQ.t2 = newQ.t2.Transform(m)
 	newQ.IDGen = IDGen{}
 	return newQ
 }
@@ -99,6 +101,18 @@ func (q *Quad) Intersect(l *Line) *Intersection {
 	return int1
 }
 
+func (q *Quad) Triangles() []Triangle {
+	return []Triangle{q.t1, q.t2}
+}
+
+func (q *Quad) Color() color.Color {
+	return q.t1.color
+}
+
+func (q *Quad) Bounds() Box {
+	return q.t1.Bounds().Union(q.t2.Bounds())
+}
+
 type Cube struct {
 	// NOTE(@lberg): pointers so we don't copy them around
 	quads []*Quad
@@ -136,6 +150,16 @@ func NewCube(w, h, d float64) (Cube, error) {
 	return cube, nil
 }
 
+// Transform returns a copy of the cube with all the faces transformed by m
+func (c Cube) Transform(m Matrix4) Cube {
+	newC := Cube{quads: make([]*Quad, len(c.quads))}
+	for idx, q := range c.quads {
+		tq := q.Transform(m)
+		newC.quads[idx] = &tq
+	}
+	return newC
+}
+
 func (c *Cube) Intersect(l *Line) *Intersection {
 	var bestInt *Intersection
 	for _, q := range c.quads {
@@ -152,3 +176,41 @@ func (c *Cube) Intersect(l *Line) *Intersection {
 	}
 	return bestInt
 }
+
+// Intervals returns the part of the line between the first and last faces crossed,
+// the cube being convex
+func (c *Cube) Intervals(l *Line) []Interval {
+	var hits []*Intersection
+	for _, q := range c.quads {
+		if inter := q.Intersect(l); inter != nil {
+			hits = append(hits, inter)
+		}
+	}
+	if len(hits) < 2 {
+		return nil
+	}
+	slices.SortFunc(hits, func(a, b *Intersection) int {
+		return cmp.Compare(a.SignedDist, b.SignedDist)
+	})
+	return []Interval{{hits[0], hits[len(hits)-1]}}
+}
+
+func (c *Cube) Triangles() []Triangle {
+	tris := make([]Triangle, 0, 2*len(c.quads))
+	for _, q := range c.quads {
+		tris = append(tris, q.t1, q.t2)
+	}
+	return tris
+}
+
+func (c *Cube) Color() color.Color {
+	return c.quads[0].Color()
+}
+
+func (c *Cube) Bounds() Box {
+	b := EmptyBox()
+	for _, q := range c.quads {
+		b = b.Union(q.Bounds())
+	}
+	return b
+}
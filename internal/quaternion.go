@@ -0,0 +1,105 @@
+package internal
+
+import "math"
+
+// Quaternion is the rotation W + Xi + Yj + Zk, rotations have a unit norm
+type Quaternion struct {
+	W, X, Y, Z float64
+}
+
+// IdentityQuaternion is the rotation which does nothing
+var IdentityQuaternion = Quaternion{W: 1}
+
+// QuaternionFromAxisAngle returns the rotation of angle around axis,
+// counter clockwise looking against the axis
+func QuaternionFromAxisAngle(axis Vector, angle Radian) Quaternion {
+	s := math.Sin(float64(angle) / 2)
+	a := axis.Normalize()
+	return Quaternion{math.Cos(float64(angle) / 2), a.X * s, a.Y * s, a.Z * s}
+}
+
+// QuaternionFromFrame returns the rotation taking I, J and K to the axes of f,
+// which must be orthonormal and right handed
+func QuaternionFromFrame(f Frame) Quaternion {
+	// NOTE(@lberg): the axes are the columns of the rotation matrix,
+	// pick the largest diagonal term to stay away from divisions by zero
+	m00, m11, m22 := f.I.X, f.J.Y, f.K.Z
+	var q Quaternion
+	switch tr := m00 + m11 + m22; {
+	case tr > 0:
+		s := 2 * math.Sqrt(tr+1)
+		q = Quaternion{s / 4, (f.J.Z - f.K.Y) / s, (f.K.X - f.I.Z) / s, (f.I.Y - f.J.X) / s}
+	case m00 > m11 && m00 > m22:
+		s := 2 * math.Sqrt(1+m00-m11-m22)
+		q = Quaternion{(f.J.Z - f.K.Y) / s, s / 4, (f.J.X + f.I.Y) / s, (f.K.X + f.I.Z) / s}
+	case m11 > m22:
+		s := 2 * math.Sqrt(1+m11-m00-m22)
+		q = Quaternion{(f.K.X - f.I.Z) / s, (f.J.X + f.I.Y) / s, s / 4, (f.K.Y + f.J.Z) / s}
+	default:
+		s := 2 * math.Sqrt(1+m22-m00-m11)
+		q = Quaternion{(f.I.Y - f.J.X) / s, (f.K.X + f.I.Z) / s, (f.K.Y + f.J.Z) / s, s / 4}
+	}
+	return q.Normalize()
+}
+
+// Mul composes the rotations, o is applied first
+func (q Quaternion) Mul(o Quaternion) Quaternion {
+	return Quaternion{
+		q.W*o.W - q.X*o.X - q.Y*o.Y - q.Z*o.Z,
+		q.W*o.X + q.X*o.W + q.Y*o.Z - q.Z*o.Y,
+		q.W*o.Y - q.X*o.Z + q.Y*o.W + q.Z*o.X,
+		q.W*o.Z + q.X*o.Y - q.Y*o.X + q.Z*o.W,
+	}
+}
+
+// Conj returns the conjugate, the inverse rotation for unit quaternions
+func (q Quaternion) Conj() Quaternion {
+	return Quaternion{q.W, -q.X, -q.Y, -q.Z}
+}
+
+func (q Quaternion) Dot(o Quaternion) float64 {
+	return q.W*o.W + q.X*o.X + q.Y*o.Y + q.Z*o.Z
+}
+
+func (q Quaternion) Norm() float64 {
+	return math.Sqrt(q.Dot(q))
+}
+
+func (q Quaternion) Normalize() Quaternion {
+	n := q.Norm()
+	if n == 0 {
+		return IdentityQuaternion
+	}
+	return Quaternion{q.W / n, q.X / n, q.Y / n, q.Z / n}
+}
+
+// Rotate applies the rotation to v
+func (q Quaternion) Rotate(v Vector) Vector {
+	// NOTE(@lberg): expanded q * v * q^-1, with u the vector part of q
+	u := Vector{q.X, q.Y, q.Z}
+	t := u.Cross(v).Mul(2)
+	return v.Add(t.Mul(q.W)).Add(u.Cross(t))
+}
+
+// Frame returns the frame in p with the axes rotated by q
+func (q Quaternion) Frame(p Vector) Frame {
+	return Frame{q.Rotate(I), q.Rotate(J), q.Rotate(K), p}
+}
+
+// Slerp interpolates along the shortest arc between the rotations a and b
+func Slerp(a, b Quaternion, t float64) Quaternion {
+	cos := a.Dot(b)
+	// q and -q are the same rotation, take the closest
+	if cos < 0 {
+		b, cos = Quaternion{-b.W, -b.X, -b.Y, -b.Z}, -cos
+	}
+	wa, wb := 1-t, t
+	if cos < 1-1e-9 {
+		theta := math.Acos(cos)
+		sin := math.Sin(theta)
+		wa, wb = math.Sin((1-t)*theta)/sin, math.Sin(t*theta)/sin
+	}
+	return Quaternion{
+		wa*a.W + wb*b.W, wa*a.X + wb*b.X, wa*a.Y + wb*b.Y, wa*a.Z + wb*b.Z,
+	}.Normalize()
+}
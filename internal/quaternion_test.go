@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuaternion(t *testing.T) {
+	axis := Vector{1, 2, -1}.Normalize()
+	q := QuaternionFromAxisAngle(axis, 0.7)
+	v := Vector{0.3, -2, 5}
+	require.InDeltaSlice(t, sl(v.Rotate(Line{Zero, axis}, 0.7)), sl(q.Rotate(v)), 1e-9)
+	require.InDeltaSlice(t, sl(v), sl(q.Conj().Rotate(q.Rotate(v))), 1e-9)
+
+	// composition applies the right operand first
+	q2 := QuaternionFromAxisAngle(K, math.Pi/2)
+	require.InDeltaSlice(t, sl(q2.Rotate(q.Rotate(v))), sl(q2.Mul(q).Rotate(v)), 1e-9)
+
+	// frames go back and forth, whichever the largest diagonal term
+	for _, f := range []Frame{
+		ZeroFrame,
+		q.Frame(Zero),
+		QuaternionFromAxisAngle(I, 3).Frame(Zero),
+		QuaternionFromAxisAngle(J, 3).Frame(Zero),
+		QuaternionFromAxisAngle(K, 3).Frame(Zero),
+		LookAt(Vector{-4, -3, 2}, Zero, K),
+	} {
+		back := QuaternionFromFrame(f).Frame(f.P)
+		require.InDeltaSlice(t, sl(f.I), sl(back.I), 1e-9)
+		require.InDeltaSlice(t, sl(f.J), sl(back.J), 1e-9)
+		require.InDeltaSlice(t, sl(f.K), sl(back.K), 1e-9)
+	}
+
+	// slerp takes the short way, even across the double cover
+	a := QuaternionFromAxisAngle(K, -0.2)
+	b := QuaternionFromAxisAngle(K, 0.4)
+	b = Quaternion{-b.W, -b.X, -b.Y, -b.Z}
+	mid := Slerp(a, b, 0.5).Rotate(I)
+	require.InDeltaSlice(t, sl(Vector{math.Cos(0.1), math.Sin(0.1), 0}), sl(mid), 1e-9)
+	require.InDelta(t, 1, Slerp(a, a, 0.3).Dot(a), 1e-12)
+}
@@ -0,0 +1,319 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// Backend is the way a render finds what is visible in each pixel
+type Backend int
+
+const (
+	// RayTracingBackend casts a ray per pixel against every renderable
+	RayTracingBackend Backend = iota
+	// RasterBackend projects the triangles of meshes on the image keeping the closest
+	// in a depth buffer, which is much faster for big meshes.
+	// Renderables which are not meshes are still ray traced.
+	RasterBackend
+)
+
+func (b Backend) String() string {
+	switch b {
+	case RasterBackend:
+		return "raster"
+	default:
+		return "raytrace"
+	}
+}
+
+// ParseBackend is the inverse of Backend.String
+func ParseBackend(s string) (Backend, error) {
+	for _, b := range []Backend{RayTracingBackend, RasterBackend} {
+		if b.String() == s {
+			return b, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown backend %q", s)
+}
+
+// Mesh is implemented by renderables made of triangles, which RasterBackend
+// draws instead of tracing rays through them
+type Mesh interface {
+	// Triangles returns the triangles placed and colored like Intersect sees them,
+	// nil if the renderable is not made of triangles. The slice can be changed.
+	Triangles() []Triangle
+}
+
+type rasterVertex struct {
+	// x, y is the position in the image and w the inverse of the depth
+	x, y, w float64
+	p       Vector
+	// bary are the barycentric coordinates in the triangle before clipping
+	bary Vector
+}
+
+type rasterTriangle struct {
+	v [3]rasterVertex
+	// area is twice the signed area in the image
+	area float64
+	tri  *Triangle
+	// hit is the index of the renderable the triangle belongs to
+	hit int
+	// internal tells the edges, opposite to each vertex, not to draw
+	internal [3]bool
+	bounds   image.Rectangle
+}
+
+// at returns the point of the triangle seen in the pixel (x, y) and its barycentric
+// coordinates, false if it is outside the triangle
+func (rt *rasterTriangle) at(x, y float64) (Vector, Vector, bool) {
+	v := &rt.v
+	l0 := rasterEdge(&v[1], &v[2], x, y) / rt.area
+	l1 := rasterEdge(&v[2], &v[0], x, y) / rt.area
+	l2 := 1 - l0 - l1
+	// NOTE(@lberg): attributes are linear in the image only once divided by the depth
+	q0, q1, q2 := l0*v[0].w, l1*v[1].w, l2*v[2].w
+	sum := q0 + q1 + q2
+	if sum <= 0 {
+		return Vector{}, Vector{}, false
+	}
+	bary := v[0].bary.Mul(q0).Add(v[1].bary.Mul(q1)).Add(v[2].bary.Mul(q2)).Mul(1 / sum)
+	// like Triangle.Intersect points on the edges are in, even slightly outside
+	if bary.X < -edgeWidth || bary.Y < -edgeWidth || bary.Z < -edgeWidth {
+		return Vector{}, Vector{}, false
+	}
+	p := v[0].p.Mul(q0).Add(v[1].p.Mul(q1)).Add(v[2].p.Mul(q2)).Mul(1 / sum)
+	return p, bary, true
+}
+
+// rasterEdge is the edge function of a, b in (x, y), positive on the left of the edge
+func rasterEdge(a, b *rasterVertex, x, y float64) float64 {
+	return (b.x-a.x)*(y-a.y) - (b.y-a.y)*(x-a.x)
+}
+
+// rasterSample is the closest triangle found in a pixel
+type rasterSample struct {
+	// tri is the index of the triangle plus one, 0 when empty
+	tri  int
+	dist float64
+	p    Vector
+	bary Vector
+}
+
+// rasterizer holds the triangles of a render projected on the image,
+// binned by the tiles they overlap
+type rasterizer struct {
+	cam    Frame
+	screen *screen
+	tris   []rasterTriangle
+	// others are the renderables which are not meshes, indexes maps them back to the render ones
+	others  []Renderable
+	indexes []int
+	size    int
+	cols    int
+	bins    [][]int
+}
+
+func newRasterizer(c *Camera, s *screen, objs []Renderable, tileSize int) *rasterizer {
+	cols, rows := (s.width+tileSize-1)/tileSize, (s.height+tileSize-1)/tileSize
+	r := &rasterizer{cam: c.F, screen: s, size: tileSize, cols: cols, bins: make([][]int, cols*rows)}
+	for idx, obj := range objs {
+		var tris []Triangle
+		if m, ok := obj.(Mesh); ok {
+			tris = m.Triangles()
+		}
+		if tris == nil {
+			r.others = append(r.others, obj)
+			r.indexes = append(r.indexes, idx)
+			continue
+		}
+		internal := internalEdges(tris)
+		for ti := range tris {
+			r.add(&tris[ti], idx, internal[ti])
+		}
+	}
+	return r
+}
+
+// project returns the position in the image of the point p in front of the camera
+func (r *rasterizer) project(p Vector, bary Vector) rasterVertex {
+	x, y, w := r.screen.project(r.cam, p)
+	return rasterVertex{x: x, y: y, w: w, p: p, bary: bary}
+}
+
+// add clips the triangle to the near plane, projects it and bins it
+func (r *rasterizer) add(t *Triangle, hit int, internal [3]bool) {
+	depth := func(p Vector) float64 {
+		return p.Sub(r.cam.P).Dot(r.cam.I) - focDis
+	}
+	corners := [3]struct{ p, bary Vector }{{t.P0, I}, {t.P1, J}, {t.P2, K}}
+	// NOTE(@lberg): Sutherland-Hodgman against the near plane, a triangle
+	// becomes a polygon of up to 4 vertices
+	var poly []rasterVertex
+	for idx, cur := range corners {
+		next := corners[(idx+1)%3]
+		d0, d1 := depth(cur.p), depth(next.p)
+		if d0 >= 0 {
+			poly = append(poly, r.project(cur.p, cur.bary))
+		}
+		if (d0 >= 0) != (d1 >= 0) {
+			f := d0 / (d0 - d1)
+			p := cur.p.Add(next.p.Sub(cur.p).Mul(f))
+			bary := cur.bary.Add(next.bary.Sub(cur.bary).Mul(f))
+			poly = append(poly, r.project(p, bary))
+		}
+	}
+	for idx := 1; idx+1 < len(poly); idx++ {
+		rt := rasterTriangle{v: [3]rasterVertex{poly[0], poly[idx], poly[idx+1]}, tri: t, hit: hit, internal: internal}
+		rt.area = rasterEdge(&rt.v[1], &rt.v[2], rt.v[0].x, rt.v[0].y)
+		if math.Abs(rt.area) < 1e-12 {
+			continue
+		}
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		for _, v := range rt.v {
+			minX, minY = min(minX, v.x), min(minY, v.y)
+			maxX, maxY = max(maxX, v.x), max(maxY, v.y)
+		}
+		// one more pixel around for the points on the edges
+		rt.bounds = image.Rect(
+			int(math.Floor(max(minX, -1)))-1, int(math.Floor(max(minY, -1)))-1,
+			int(math.Ceil(min(maxX, float64(r.screen.width))))+2, int(math.Ceil(min(maxY, float64(r.screen.height))))+2,
+		).Intersect(image.Rect(0, 0, r.screen.width, r.screen.height))
+		if rt.bounds.Empty() {
+			continue
+		}
+		r.tris = append(r.tris, rt)
+		ti := len(r.tris) - 1
+		for row := rt.bounds.Min.Y / r.size; row <= (rt.bounds.Max.Y-1)/r.size; row++ {
+			for col := rt.bounds.Min.X / r.size; col <= (rt.bounds.Max.X-1)/r.size; col++ {
+				r.bins[row*r.cols+col] = append(r.bins[row*r.cols+col], ti)
+			}
+		}
+	}
+}
+
+// draw returns the closest triangle in the pixels of tile aligned to step,
+// indexed by row then column from the tile corner
+func (r *rasterizer) draw(tile image.Rectangle, step int) []rasterSample {
+	samples := make([]rasterSample, tile.Dx()*tile.Dy())
+	bin := r.bins[tile.Min.Y/r.size*r.cols+tile.Min.X/r.size]
+	for _, ti := range bin {
+		rt := &r.tris[ti]
+		area := rt.bounds.Intersect(tile)
+		startX := (area.Min.X + step - 1) / step * step
+		startY := (area.Min.Y + step - 1) / step * step
+		for y := startY; y < area.Max.Y; y += step {
+			for x := startX; x < area.Max.X; x += step {
+				p, bary, ok := rt.at(float64(x), float64(y))
+				if !ok {
+					continue
+				}
+				// NOTE(@lberg): distances along the rays, as the ray tracer compares them,
+				// earlier renderables win ties like there
+				dist := p.Sub(r.cam.P).Norm()
+				if dist <= focDis {
+					continue
+				}
+				s := &samples[(y-tile.Min.Y)*tile.Dx()+x-tile.Min.X]
+				if s.tri != 0 && dist >= s.dist {
+					continue
+				}
+				*s = rasterSample{ti + 1, dist, p, bary}
+			}
+		}
+	}
+	return samples
+}
+
+// at returns the intersection in the pixel (x, y) of tile given the samples drawn for it,
+// ray tracing l through the renderables which are not meshes
+func (r *rasterizer) at(samples []rasterSample, tile image.Rectangle, x, y int, l *Line) (*Intersection, int) {
+	var inter *Intersection
+	hit := -1
+	if s := samples[(y-tile.Min.Y)*tile.Dx()+x-tile.Min.X]; s.tri != 0 {
+		inter, hit = r.intersection(&s)
+	}
+	if len(r.others) > 0 {
+		other, idx := trace(l, r.others)
+		if other != nil && (inter == nil || other.SignedDist < inter.SignedDist) {
+			inter, hit = other, r.indexes[idx]
+		}
+	}
+	return inter, hit
+}
+
+// intersection builds the intersection Triangle.Intersect would have returned for the sample
+func (r *rasterizer) intersection(s *rasterSample) (*Intersection, int) {
+	rt := &r.tris[s.tri-1]
+	t := rt.tri
+	where := inside
+	for idx, bc := range [3]float64{s.bary.X, s.bary.Y, s.bary.Z} {
+		if rt.internal[idx] || math.Abs(bc) > edgeWidth {
+			continue
+		}
+		if where == inside {
+			where = edge
+		} else {
+			where = corner
+		}
+	}
+	col := t.color
+	if t.edgeColor != nil && where != inside {
+		col = *t.edgeColor
+	}
+	normal := t.planeData.plane.NormV
+	if normal.Dot(s.p.Sub(r.cam.P)) > 0 {
+		normal = normal.Neg()
+	}
+	return &Intersection{
+		IntPoint:   s.p,
+		SignedDist: s.dist,
+		Normal:     normal,
+		Color:      col,
+		Where:      where,
+		base:       t.color,
+	}, rt.hit
+}
+
+// internalEdges tells for every triangle the edges, opposite to each vertex,
+// shared with a coplanar triangle like the diagonal of a quad
+func internalEdges(tris []Triangle) [][3]bool {
+	edges := func(t *Triangle) [3]edgeKey {
+		return [3]edgeKey{newEdgeKey(t.P1, t.P2), newEdgeKey(t.P2, t.P0), newEdgeKey(t.P0, t.P1)}
+	}
+	owners := make(map[edgeKey][]int)
+	for idx := range tris {
+		for _, k := range edges(&tris[idx]) {
+			owners[k] = append(owners[k], idx)
+		}
+	}
+	internal := make([][3]bool, len(tris))
+	for idx := range tris {
+		n := tris[idx].planeData.plane.NormV
+		for e, k := range edges(&tris[idx]) {
+			for _, other := range owners[k] {
+				if other != idx && math.Abs(n.Dot(tris[other].planeData.plane.NormV)) > 1-1e-9 {
+					internal[idx][e] = true
+				}
+			}
+		}
+	}
+	return internal
+}
+
+// edgeKey identifies an edge whatever the order of its ends
+type edgeKey [2]Vector
+
+func newEdgeKey(a, b Vector) edgeKey {
+	// NOTE(@lberg): ends computed in different ways by neighbour faces may differ in the last bits
+	snap := func(v Vector) Vector {
+		return Vector{math.Round(v.X*1e9) / 1e9, math.Round(v.Y*1e9) / 1e9, math.Round(v.Z*1e9) / 1e9}
+	}
+	a, b = snap(a), snap(b)
+	if a.X > b.X || (a.X == b.X && (a.Y > b.Y || (a.Y == b.Y && a.Z > b.Z))) {
+		a, b = b, a
+	}
+	return edgeKey{a, b}
+}
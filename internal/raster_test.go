@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rasterScene has cubes, placed, recolored, selected and instanced, a sphere which
+// is not a mesh and a floor going behind the camera
+func rasterScene(t testing.TB) *Engine {
+	engine := NewEngine()
+	engine.RepositionCamera(func(Frame) Frame {
+		return LookAt(Vector{-4, 2, 1.5}, Zero, K)
+	})
+	cube, err := NewCube(1, 1, 1)
+	require.NoError(t, err)
+	turned := cube.Transform(RotationAround(NewLine(Zero, K), 0.5).Mul(Translation(J.Mul(1.5))))
+	in, err := NewInstance(&cube, Compose(Vector{0, -1.5, 0}, QuaternionFromAxisAngle(I, 0.3), Vector{1, 0.5, 2}))
+	require.NoError(t, err)
+	sphere, err := NewSphere(Vector{1, 0, 1}, 0.6)
+	require.NoError(t, err)
+	floor, err := NewQuad(Vector{-20, -20, -0.5}, Vector{20, -20, -0.5}, Vector{-20, 20, -0.5}, Vector{20, 20, -0.5},
+		WithQuadColor(color.RGBA{200, 200, 200, 255}), WithQuadEdgeColor(color.Black))
+	require.NoError(t, err)
+	hs := engine.Add(&cube, &turned, &in, &sphere, &floor)
+	engine.Select(hs[1])
+	engine.Recolor(hs[0], color.RGBA{0, 0, 255, 255})
+	engine.Place(hs[2], func(f Frame) Frame { return f.Move(K.Mul(0.2)) })
+	return engine
+}
+
+func TestRasterBackend(t *testing.T) {
+	_, err := ParseBackend("scanline")
+	require.Error(t, err)
+	b, err := ParseBackend(RasterBackend.String())
+	require.NoError(t, err)
+	require.Equal(t, RasterBackend, b)
+
+	engine := rasterScene(t)
+	var traced, rastered AOVs
+	want, err := engine.Render(context.Background(), 300, 1.5, WithAOVs(&traced, IDAOV))
+	require.NoError(t, err)
+	got, err := engine.Render(context.Background(), 300, 1.5, WithBackend(RasterBackend), WithAOVs(&rastered, IDAOV))
+	require.NoError(t, err)
+	require.Equal(t, want.Rect, got.Rect)
+
+	// only a few pixels on the edges may round differently
+	diff := 0
+	for y := range want.Rect.Dy() {
+		for x := range want.Rect.Dx() {
+			if want.RGBAAt(x, y) != got.RGBAAt(x, y) || traced.IDAt(x, y) != rastered.IDAt(x, y) {
+				diff++
+			}
+		}
+	}
+	require.Less(t, diff, want.Rect.Dx()*want.Rect.Dy()/200)
+
+	// the diagonals of the quads are not edges
+	quad, err := NewQuad(Vector{0, -1, -1}, Vector{0, 1, -1}, Vector{0, -1, 1}, Vector{0, 1, 1},
+		WithQuadColor(color.RGBA{255, 0, 0, 255}), WithQuadEdgeColor(color.Black))
+	require.NoError(t, err)
+	flat := NewEngine()
+	flat.RepositionCamera(func(f Frame) Frame { return f.Move(I.Mul(-2)) })
+	flat.Add(&quad)
+	img, err := flat.Render(context.Background(), 101, 1, WithBackend(RasterBackend))
+	require.NoError(t, err)
+	require.Equal(t, color.RGBA{255, 0, 0, 255}, img.RGBAAt(50, 50))
+	require.Equal(t, color.RGBA{255, 0, 0, 255}, img.RGBAAt(40, 40))
+	require.Equal(t, color.RGBA{255, 0, 0, 255}, img.RGBAAt(60, 40))
+}
+
+func TestRasterProgress(t *testing.T) {
+	engine := rasterScene(t)
+	var passes []*image.RGBA
+	img, err := engine.Render(context.Background(), 128, 1, WithBackend(RasterBackend),
+		WithProgress(8, func(img *image.RGBA, pass, total int) bool {
+			passes = append(passes, image.NewRGBA(img.Rect))
+			copy(passes[len(passes)-1].Pix, img.Pix)
+			return true
+		}))
+	require.NoError(t, err)
+	require.Len(t, passes, 4)
+	full, err := engine.Render(context.Background(), 128, 1, WithBackend(RasterBackend))
+	require.NoError(t, err)
+	require.Equal(t, full.Pix, img.Pix)
+	// the coarse pass repeats the pixels of the grid
+	require.Equal(t, full.RGBAAt(64, 64), passes[0].RGBAAt(70, 70))
+}
+
+func BenchmarkRaster(b *testing.B) {
+	engine := NewEngine()
+	engine.RepositionCamera(func(f Frame) Frame {
+		return LookAt(Vector{-12, 6, 6}, Zero, K)
+	})
+	for x := range 10 {
+		for y := range 10 {
+			cube, _ := NewCube(0.8, 0.8, 0.8)
+			cube = cube.Transform(Translation(Vector{float64(x) - 5, float64(y) - 5, 0}))
+			engine.Add(&cube)
+		}
+	}
+	for _, backend := range []Backend{RayTracingBackend, RasterBackend} {
+		b.Run(backend.String(), func(b *testing.B) {
+			for b.Loop() {
+				engine.Render(context.Background(), 128, 1, WithBackend(backend))
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strings"
+)
+
+// toRGBE encodes a color with a shared exponent, as in Radiance .hdr files
+func toRGBE(c LinearColor) [4]byte {
+	v := float64(max(c.R, c.G, c.B))
+	if v < 1e-32 {
+		return [4]byte{}
+	}
+	m, e := math.Frexp(v)
+	scale := m * 256 / v
+	return [4]byte{
+		byte(float64(max(c.R, 0)) * scale),
+		byte(float64(max(c.G, 0)) * scale),
+		byte(float64(max(c.B, 0)) * scale),
+		byte(e + 128),
+	}
+}
+
+func fromRGBE(p [4]byte) LinearColor {
+	if p[3] == 0 {
+		return LinearColor{A: 1}
+	}
+	// NOTE(@lberg): the half moves values to the middle of their quantization step
+	f := float32(math.Ldexp(1, int(p[3])-128-8))
+	return LinearColor{(float32(p[0]) + 0.5) * f, (float32(p[1]) + 0.5) * f, (float32(p[2]) + 0.5) * f, 1}
+}
+
+// WriteRGBE writes fb as a run length encoded Radiance .hdr file.
+// The format has no alpha, colors are written as they are so premultiplied.
+func WriteRGBE(w io.Writer, fb *Framebuffer) error {
+	width, height := fb.Rect.Dx(), fb.Rect.Dy()
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("empty hdr image %v", fb.Rect)
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", height, width)
+	line := make([][4]byte, width)
+	for y := fb.Rect.Min.Y; y < fb.Rect.Max.Y; y++ {
+		for x := range line {
+			line[x] = toRGBE(fb.Pix[fb.offset(fb.Rect.Min.X+x, y)])
+		}
+		// NOTE(@lberg): the run length encoding only exists for these widths
+		if width < 8 || width > 0x7fff {
+			for _, p := range line {
+				bw.Write(p[:])
+			}
+			continue
+		}
+		bw.Write([]byte{2, 2, byte(width >> 8), byte(width)})
+		comp := make([]byte, width)
+		for c := range 4 {
+			for x, p := range line {
+				comp[x] = p[c]
+			}
+			writeRLE(bw, comp)
+		}
+	}
+	return bw.Flush()
+}
+
+// writeRLE writes a component of a scanline as runs of equal bytes,
+// a count above 128 is a run of count-128 times the next byte,
+// otherwise the count is followed by as many literal bytes
+func writeRLE(w *bufio.Writer, b []byte) {
+	const minRun = 4
+	for len(b) > 0 {
+		// find the next run worth encoding
+		lit := 0
+		run := 1
+		for lit < len(b) {
+			run = 1
+			for lit+run < len(b) && run < 127 && b[lit+run] == b[lit] {
+				run++
+			}
+			if run >= minRun {
+				break
+			}
+			lit += run
+		}
+		// literals go first, in chunks of at most 128
+		for lit > 0 {
+			n := min(lit, 128)
+			w.WriteByte(byte(n))
+			w.Write(b[:n])
+			b, lit = b[n:], lit-n
+		}
+		if run >= minRun && len(b) > 0 {
+			w.WriteByte(byte(128 + run))
+			w.WriteByte(b[0])
+			b = b[run:]
+		}
+	}
+}
+
+// ReadRGBE reads a Radiance .hdr file with either flat or run length encoded scanlines,
+// only the usual -Y h +X w orientation is supported
+func ReadRGBE(src io.Reader) (*Framebuffer, error) {
+	r := bufio.NewReader(src)
+	magic, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(magic, "#?") {
+		return nil, errors.New("not an hdr file")
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("hdr header: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if format, ok := strings.CutPrefix(line, "FORMAT="); ok && format != "32-bit_rle_rgbe" {
+			return nil, fmt.Errorf("unsupported hdr format %q", format)
+		}
+	}
+	res, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("hdr resolution: %w", err)
+	}
+	var width, height int
+	if _, err := fmt.Sscanf(res, "-Y %d +X %d", &height, &width); err != nil {
+		return nil, fmt.Errorf("unsupported hdr resolution %q", strings.TrimSpace(res))
+	}
+	if width <= 0 || height <= 0 || width > 1<<20 || height > 1<<20 {
+		return nil, fmt.Errorf("invalid hdr size %dx%d", width, height)
+	}
+
+	fb := NewFramebuffer(image.Rect(0, 0, width, height))
+	line := make([][4]byte, width)
+	for y := range height {
+		if err := readRGBELine(r, line); err != nil {
+			return nil, fmt.Errorf("hdr scanline %d: %w", y, err)
+		}
+		for x, p := range line {
+			fb.Pix[fb.offset(x, y)] = fromRGBE(p)
+		}
+	}
+	return fb, nil
+}
+
+func readRGBELine(r *bufio.Reader, line [][4]byte) error {
+	var p [4]byte
+	if _, err := io.ReadFull(r, p[:]); err != nil {
+		return err
+	}
+	if p[0] != 2 || p[1] != 2 || p[2]&0x80 != 0 || len(line) < 8 || len(line) > 0x7fff {
+		// flat scanline, p is its first pixel
+		line[0] = p
+		for x := 1; x < len(line); x++ {
+			if _, err := io.ReadFull(r, line[x][:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if width := int(p[2])<<8 | int(p[3]); width != len(line) {
+		return fmt.Errorf("scanline width %d, want %d", width, len(line))
+	}
+	for c := range 4 {
+		for x := 0; x < len(line); {
+			count, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			if count > 128 {
+				n := int(count - 128)
+				v, err := r.ReadByte()
+				if err != nil {
+					return err
+				}
+				if x+n > len(line) {
+					return errors.New("run past the end of the scanline")
+				}
+				for range n {
+					line[x][c] = v
+					x++
+				}
+				continue
+			}
+			n := int(count)
+			if n == 0 || x+n > len(line) {
+				return errors.New("bad literal count")
+			}
+			for range n {
+				v, err := r.ReadByte()
+				if err != nil {
+					return err
+				}
+				line[x][c] = v
+				x++
+			}
+		}
+	}
+	return nil
+}
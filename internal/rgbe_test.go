@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"bytes"
+	"image"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// hdrTestImage is an opaque framebuffer with noise, flat areas for runs and values above 1
+func hdrTestImage(width, height int) *Framebuffer {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	fb := NewFramebuffer(image.Rect(0, 0, width, height))
+	for idx := range fb.Pix {
+		if idx%width < width/2 {
+			fb.Pix[idx] = LinearColor{0.5, 2, 40, 1}
+			continue
+		}
+		fb.Pix[idx] = LinearColor{rnd.Float32() * 10, rnd.Float32(), rnd.Float32() / 100, 1}
+	}
+	fb.Pix[0] = LinearColor{A: 1}
+	return fb
+}
+
+func TestRGBERoundTrip(t *testing.T) {
+	// the run length encoding is used from 8 pixels
+	for _, width := range []int{5, 300} {
+		fb := hdrTestImage(width, 7)
+		var buf bytes.Buffer
+		require.NoError(t, WriteRGBE(&buf, fb))
+		got, err := ReadRGBE(&buf)
+		require.NoError(t, err)
+		require.Equal(t, fb.Rect, got.Rect)
+		for idx, want := range fb.Pix {
+			c := got.Pix[idx]
+			// NOTE(@lberg): channels share the exponent so they are precise relative to the largest
+			tol := float64(max(want.R, want.G, want.B)) / 128
+			require.InDelta(t, want.R, c.R, tol)
+			require.InDelta(t, want.G, c.G, tol)
+			require.InDelta(t, want.B, c.B, tol)
+			require.Equal(t, float32(1), c.A)
+		}
+		if width > 8 {
+			// the flat half compresses well
+			require.Less(t, buf.Len(), 4*width*7*3/4)
+		}
+	}
+
+	_, err := ReadRGBE(bytes.NewReader([]byte("#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n-Y 1 +X 1\n")))
+	require.Error(t, err)
+	_, err = ReadRGBE(bytes.NewReader([]byte("#?RADIANCE\n\n-Y 2 +X 1\n\x01\x01\x01\x80")))
+	require.Error(t, err)
+}
+
+func TestPFMRoundTrip(t *testing.T) {
+	fb := hdrTestImage(13, 4)
+	var buf bytes.Buffer
+	require.NoError(t, WritePFM(&buf, fb))
+	got, err := ReadPFM(&buf)
+	require.NoError(t, err)
+	require.Equal(t, fb.Pix, got.Pix)
+
+	// greyscale, big endian, bottom row first
+	grey := []byte("Pf\n2 2\n1.0\n")
+	for _, v := range [][]byte{{0x3f, 0x80, 0, 0}, {0x40, 0, 0, 0}, {0x40, 0x40, 0, 0}, {0x40, 0x80, 0, 0}} {
+		grey = append(grey, v...)
+	}
+	got, err = ReadPFM(bytes.NewReader(grey))
+	require.NoError(t, err)
+	require.Equal(t, []LinearColor{{3, 3, 3, 1}, {4, 4, 4, 1}, {1, 1, 1, 1}, {2, 2, 2, 1}}, got.Pix)
+
+	_, err = ReadPFM(bytes.NewReader(grey[:len(grey)-1]))
+	require.Error(t, err)
+}
@@ -0,0 +1,227 @@
+package internal
+
+import (
+	"image/color"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+)
+
+// scene is the state of the engine at a given version.
+// Scenes are never modified once published, changes create a new one.
+type scene struct {
+	version  uint64
+	camera   Camera
+	entities map[Handle]entity
+	selected Handle
+	// timeline animates the scene, it is evaluated at time
+	timeline *Timeline
+	time     float64
+	// world are the entities placed in world coordinates with the selection flag set,
+	// built once per scene when first needed, objs are the ones with something to render
+	worldOnce sync.Once
+	world     map[Handle]*entity
+	objs      []Renderable
+	// children are the handles of the children of each node, built once per scene when first needed
+	childrenOnce sync.Once
+	children     map[Handle][]Handle
+}
+
+// clone returns a copy of the scene sharing the entities,
+// which need to be cloned as well before being changed
+func (s *scene) clone() *scene {
+	return &scene{
+		version:  s.version,
+		camera:   s.camera,
+		entities: s.entities,
+		selected: s.selected,
+		timeline: s.timeline,
+		time:     s.time,
+	}
+}
+
+// placed returns the entities in world coordinates keyed by id.
+// Descendants of the selected entity are selected as well.
+func (s *scene) placed() map[Handle]*entity {
+	s.worldOnce.Do(func() {
+		s.world = make(map[Handle]*entity, len(s.entities))
+		var place func(id Handle) *entity
+		place = func(id Handle) *entity {
+			if en, ok := s.world[id]; ok {
+				return en
+			}
+			en := s.entities[id]
+			if _, ok := s.entities[en.parent]; ok {
+				p := place(en.parent)
+				en.place = p.place.FrameToWorld(en.place)
+				en.selected = p.selected
+			}
+			en.selected = en.selected || id == s.selected
+			s.world[id] = &en
+			return &en
+		}
+		// NOTE(@lberg): objs are in handle order, renders must not depend on the map order
+		for _, id := range slices.Sorted(maps.Keys(s.entities)) {
+			en := place(id)
+			if _, ok := en.r.(*Node); !ok {
+				s.objs = append(s.objs, en)
+			}
+		}
+	})
+	return s.world
+}
+
+// worldPlace computes the world placement of the entity with the given handle,
+// ZeroFrame if there is none
+func (s *scene) worldPlace(id Handle) Frame {
+	en, ok := s.entities[id]
+	if !ok {
+		return ZeroFrame
+	}
+	return s.worldPlace(en.parent).FrameToWorld(en.place)
+}
+
+// renderables returns the placed entities with some geometry
+func (s *scene) renderables() []Renderable {
+	s.placed()
+	return s.objs
+}
+
+// subtree returns the handles of the entity with the given handle and of all its descendants.
+// The scene must not be changed afterwards, the children index is built only once.
+func (s *scene) subtree(id Handle) []Handle {
+	s.childrenOnce.Do(func() {
+		s.children = make(map[Handle][]Handle)
+		for other, en := range s.entities {
+			if en.parent != "" {
+				s.children[en.parent] = append(s.children[en.parent], other)
+			}
+		}
+	})
+	ids := []Handle{id}
+	for idx := 0; idx < len(ids); idx++ {
+		ids = append(ids, s.children[ids[idx]]...)
+	}
+	return ids
+}
+
+// entity is a renderable placed in the world
+type entity struct {
+	// handle is the entity id, the renderable id when it was added
+	handle Handle
+	r      Renderable
+	// parent is the node the entity belongs to, empty at the top level
+	parent Handle
+	// place maps the renderable coordinates to the parent ones,
+	// which are the world ones at the top level and for placed entities
+	place Frame
+	// color replaces the renderable base color when not nil
+	color    color.Color
+	selected bool
+}
+
+// ID returns the handle, which stays the same when the renderable is replaced
+func (en *entity) ID() string {
+	return string(en.handle)
+}
+
+func (en *entity) Intersect(l *Line) *Intersection {
+	// NOTE(@lberg): frames are rigid so we can intersect in the renderable
+	// coordinates and only map back the point, distances do not change
+	placed := en.place != ZeroFrame
+	if placed {
+		local := Line{en.place.ToLocal(l.P), en.place.DirToLocal(l.Dir)}
+		l = &local
+	}
+	inter := en.r.Intersect(l)
+	if inter == nil {
+		return nil
+	}
+	if placed {
+		inter.IntPoint = en.place.ToWorld(inter.IntPoint)
+		inter.Normal = en.place.DirToWorld(inter.Normal)
+	}
+	if en.color != nil {
+		recolor(inter, en.r, en.color)
+	}
+	if en.selected {
+		if inter.Where == inside {
+			inter.Color = mix(inter.Color, SelectionColor, 0.4)
+		} else {
+			inter.Color = SelectionColor
+		}
+		if inter.base != nil {
+			inter.base = mix(inter.base, SelectionColor, 0.4)
+		}
+	}
+	return inter
+}
+
+// Triangles returns the triangles of the renderable placed and colored like Intersect does,
+// nil if it is not a Mesh
+func (en *entity) Triangles() []Triangle {
+	m, ok := en.r.(Mesh)
+	if !ok {
+		return nil
+	}
+	tris := m.Triangles()
+	place := MatrixFromFrame(en.place)
+	for idx := range tris {
+		t := &tris[idx]
+		if en.place != ZeroFrame {
+			*t = t.Transform(place)
+		}
+		if en.color != nil {
+			recolorTriangle(t, en.r, en.color)
+		}
+		if en.selected {
+			sel := SelectionColor
+			t.color = mix(t.color, sel, 0.4)
+			t.edgeColor = &sel
+		}
+	}
+	return tris
+}
+
+// Bounds returns the world box of the entity, empty if the renderable is not Bounded
+func (en *entity) Bounds() Box {
+	br, ok := en.r.(Bounded)
+	if !ok {
+		return EmptyBox()
+	}
+	b := br.Bounds()
+	if en.place == ZeroFrame {
+		return b
+	}
+	return b.Map(en.place.ToWorld)
+}
+
+// recolor paints the intersection with r with c, edge colors are kept
+func recolor(inter *Intersection, r Renderable, c color.Color) {
+	base, ok := r.(Colored)
+	if inter.Where == inside || (ok && inter.Color == base.Color()) {
+		inter.Color = c
+	}
+	if inter.base != nil {
+		inter.base = c
+	}
+}
+
+// recolorTriangle is recolor for the triangles of a Mesh
+func recolorTriangle(t *Triangle, r Renderable, c color.Color) {
+	if base, ok := r.(Colored); ok && t.edgeColor != nil && *t.edgeColor == base.Color() {
+		t.edgeColor = &c
+	}
+	t.color = c
+}
+
+// mix linearly blends c0 towards c1
+func mix(c0, c1 color.Color, t float64) color.Color {
+	r0, g0, b0, a0 := c0.RGBA()
+	r1, g1, b1, a1 := c1.RGBA()
+	m := func(v0, v1 uint32) uint8 {
+		return uint8(math.Round((float64(v0)*(1-t) + float64(v1)*t) / 257))
+	}
+	return color.RGBA{m(r0, r1), m(g0, g1), m(b0, b1), m(a0, a1)}
+}
@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// Distance is a signed distance function, it returns the distance from p
+// to the closest point of a surface, negative inside it.
+// Sphere tracing needs it to never overestimate the distance.
+type Distance func(p Vector) float64
+
+// SphereSDF is a sphere around the origin
+func SphereSDF(radius float64) Distance {
+	return func(p Vector) float64 {
+		return p.Norm() - radius
+	}
+}
+
+// BoxSDF is a box around the origin, half is the distance from the centre to its faces
+func BoxSDF(half Vector) Distance {
+	return func(p Vector) float64 {
+		q := Vector{math.Abs(p.X) - half.X, math.Abs(p.Y) - half.Y, math.Abs(p.Z) - half.Z}
+		outside := Vector{max(q.X, 0), max(q.Y, 0), max(q.Z, 0)}.Norm()
+		return outside + min(max(q.X, q.Y, q.Z), 0)
+	}
+}
+
+// TorusSDF is a torus around K, major is the distance from the centre to the middle of the tube
+func TorusSDF(major, minor float64) Distance {
+	return func(p Vector) float64 {
+		q := math.Hypot(math.Hypot(p.X, p.Y)-major, p.Z)
+		return q - minor
+	}
+}
+
+// MengerSDF is the Menger sponge fractal, a cube of side 2*half with square holes
+// carved along each axis, recursively, iterations times
+func MengerSDF(half float64, iterations int) Distance {
+	cube := BoxSDF(Vector{1, 1, 1})
+	return func(p Vector) float64 {
+		p = p.Mul(1 / half)
+		d := cube(p)
+		s := 1.
+		for range iterations {
+			// NOTE(@lberg): fold space in the cells of the current level, then carve
+			// the cross made of three infinite square bars of the cell
+			a := Vector{mod(p.X*s, 2) - 1, mod(p.Y*s, 2) - 1, mod(p.Z*s, 2) - 1}
+			s *= 3
+			r := Vector{
+				math.Abs(1 - 3*math.Abs(a.X)),
+				math.Abs(1 - 3*math.Abs(a.Y)),
+				math.Abs(1 - 3*math.Abs(a.Z)),
+			}
+			cross := (min(max(r.X, r.Y), max(r.Y, r.Z), max(r.Z, r.X)) - 1) / s
+			d = max(d, cross)
+		}
+		return d * half
+	}
+}
+
+// mod is the remainder of x/y with the sign of y
+func mod(x, y float64) float64 {
+	return x - y*math.Floor(x/y)
+}
+
+// Move moves the surface by v
+func (d Distance) Move(v Vector) Distance {
+	return func(p Vector) float64 {
+		return d(p.Sub(v))
+	}
+}
+
+// Round inflates the surface by radius, rounding its edges
+func (d Distance) Round(radius float64) Distance {
+	return func(p Vector) float64 {
+		return d(p) - radius
+	}
+}
+
+// SmoothUnion merges the surfaces, blending them where they are closer than k
+func (d Distance) SmoothUnion(o Distance, k float64) Distance {
+	return func(p Vector) float64 {
+		a, b := d(p), o(p)
+		if k <= 0 {
+			return min(a, b)
+		}
+		h := min(max(0.5+0.5*(b-a)/k, 0), 1)
+		return b*(1-h) + a*h - k*h*(1-h)
+	}
+}
+
+// Twist rotates the surface around K by rate radians per unit along K.
+// The result overestimates distances far from the axis, see WithSDFStepScale.
+func (d Distance) Twist(rate float64) Distance {
+	return func(p Vector) float64 {
+		sin, cos := math.Sincos(-rate * p.Z)
+		return d(Vector{cos*p.X - sin*p.Y, sin*p.X + cos*p.Y, p.Z})
+	}
+}
+
+// Repeat repeats the surface forever with the given period along each axis,
+// 0 leaves an axis alone. The surface must fit in a period around the origin.
+func (d Distance) Repeat(period Vector) Distance {
+	wrap := func(x, period float64) float64 {
+		if period == 0 {
+			return x
+		}
+		return x - period*math.Round(x/period)
+	}
+	return func(p Vector) float64 {
+		return d(Vector{wrap(p.X, period.X), wrap(p.Y, period.Y), wrap(p.Z, period.Z)})
+	}
+}
+
+// SDF renders the surface of a distance function by sphere tracing,
+// stepping along the line by the distance to the surface until close enough
+type SDF struct {
+	dist  Distance
+	color color.Color
+	// bounds are optional, they limit the tracing to the box
+	bounds *Box
+	// steps and maxDist limit the tracing, precision is how close is a hit
+	steps            int
+	maxDist          float64
+	precision, scale float64
+	IDGen
+}
+
+type sdfOption func(*SDF)
+
+func WithSDFColor(c color.Color) sdfOption {
+	return func(s *SDF) {
+		s.color = c
+	}
+}
+
+// WithSDFBounds limits the tracing to a box containing the surface,
+// which speeds it up and gives the SDF bounds
+func WithSDFBounds(b Box) sdfOption {
+	return func(s *SDF) {
+		s.bounds = &b
+	}
+}
+
+// WithSDFSteps stops the tracing after the given number of steps
+// or once further than maxDist from the origin of the line
+func WithSDFSteps(steps int, maxDist float64) sdfOption {
+	return func(s *SDF) {
+		s.steps = steps
+		s.maxDist = maxDist
+	}
+}
+
+// WithSDFPrecision sets how close to the surface counts as a hit
+func WithSDFPrecision(precision float64) sdfOption {
+	return func(s *SDF) {
+		s.precision = precision
+	}
+}
+
+// WithSDFStepScale shortens the steps by scale in (0, 1],
+// for distance functions overestimating the distance like twisted ones
+func WithSDFStepScale(scale float64) sdfOption {
+	return func(s *SDF) {
+		s.scale = scale
+	}
+}
+
+func NewSDF(d Distance, opts ...sdfOption) (SDF, error) {
+	if d == nil {
+		return SDF{}, fmt.Errorf("missing distance function")
+	}
+	s := SDF{
+		dist:      d,
+		color:     color.RGBA{255, 0, 0, 255},
+		steps:     256,
+		maxDist:   1e3,
+		precision: 1e-4,
+		scale:     1,
+	}
+	for _, op := range opts {
+		op(&s)
+	}
+	if s.steps <= 0 || s.maxDist <= 0 || s.precision <= 0 || s.scale <= 0 || s.scale > 1 {
+		return SDF{}, fmt.Errorf("invalid tracing limits")
+	}
+	return s, nil
+}
+
+func (s *SDF) Intersect(l *Line) *Intersection {
+	dir := l.Dir.Normalize()
+	t, end := 0., s.maxDist
+	if s.bounds != nil {
+		t0, t1, ok := s.bounds.clip(&Line{l.P, dir})
+		if !ok || t1 < 0 {
+			return nil
+		}
+		t, end = max(t, t0), min(end, t1)
+	}
+	for range s.steps {
+		if t > end {
+			return nil
+		}
+		p := l.P.Add(dir.Mul(t))
+		// NOTE(@lberg): the absolute distance lets lines starting inside find the surface
+		d := math.Abs(s.dist(p))
+		if d < s.precision {
+			return &Intersection{
+				IntPoint:   p,
+				SignedDist: t,
+				Normal:     s.normal(p, dir),
+				Color:      s.color,
+				Where:      inside,
+			}
+		}
+		t += d * s.scale
+	}
+	return nil
+}
+
+// normal is the gradient of the distance in p, facing against dir
+func (s *SDF) normal(p, dir Vector) Vector {
+	h := s.precision
+	grad := Vector{
+		s.dist(p.Add(I.Mul(h))) - s.dist(p.Sub(I.Mul(h))),
+		s.dist(p.Add(J.Mul(h))) - s.dist(p.Sub(J.Mul(h))),
+		s.dist(p.Add(K.Mul(h))) - s.dist(p.Sub(K.Mul(h))),
+	}.Normalize()
+	if grad.Dot(dir) > 0 {
+		grad = grad.Neg()
+	}
+	return grad
+}
+
+func (s *SDF) Color() color.Color {
+	return s.color
+}
+
+// Bounds returns the box given with WithSDFBounds, empty without it
+func (s *SDF) Bounds() Box {
+	if s.bounds == nil {
+		return EmptyBox()
+	}
+	return *s.bounds
+}
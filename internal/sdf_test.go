@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSDFShapes(t *testing.T) {
+	box := BoxSDF(Vector{1, 2, 3})
+	require.InDelta(t, -1, box(Zero), 1e-9)
+	require.InDelta(t, 1, box(Vector{2, 0, 0}), 1e-9)
+	require.InDelta(t, math.Sqrt2, box(Vector{2, 3, 0}), 1e-9)
+	require.InDelta(t, 0.5, box.Round(0.5)(Vector{2, 0, 0}), 1e-9)
+	require.InDelta(t, 0, TorusSDF(2, 0.5)(Vector{0, 2.5, 0}), 1e-9)
+	require.InDelta(t, 1, SphereSDF(1).Move(K.Mul(3))(K), 1e-9)
+
+	// the blend is below both surfaces between them, the same far from them
+	a, b := SphereSDF(1).Move(I.Neg()), SphereSDF(1).Move(I)
+	blend := a.SmoothUnion(b, 0.5)
+	require.Less(t, blend(Vector{0, 0, 0.9}), min(a(Vector{0, 0, 0.9}), b(Vector{0, 0, 0.9})))
+	require.InDelta(t, a(I.Mul(-3)), blend(I.Mul(-3)), 1e-9)
+
+	// a quarter turn at z = 1 maps the box I side on J
+	twisted := BoxSDF(Vector{2, 0.5, 5}).Twist(math.Pi / 2)
+	require.InDelta(t, 0, twisted(Vector{0, 2, 1}), 1e-9)
+	require.InDelta(t, 0, twisted(Vector{2, 0, 0}), 1e-9)
+
+	repeated := SphereSDF(1).Repeat(Vector{4, 0, 0})
+	require.InDelta(t, -1, repeated(I.Mul(8)), 1e-9)
+	require.InDelta(t, 1, repeated(Vector{8, 2, 0}), 1e-9)
+	require.InDelta(t, 3, repeated(Vector{0, 0, 4}), 1e-9)
+
+	// the centre of the sponge and the middle of its faces are carved
+	sponge := MengerSDF(1, 2)
+	require.Greater(t, sponge(Zero), 0.)
+	require.Greater(t, sponge(Vector{0.9, 0, 0}), 0.)
+	require.Less(t, sponge(Vector{0.9, 0.9, 0.9}), 0.)
+}
+
+func TestSDF(t *testing.T) {
+	_, err := NewSDF(nil)
+	require.Error(t, err)
+	_, err = NewSDF(SphereSDF(1), WithSDFStepScale(2))
+	require.Error(t, err)
+
+	ball, err := NewSDF(SphereSDF(1).Move(K))
+	require.NoError(t, err)
+	l := NewLine(Vector{-5, 0, 1}, I)
+	inter := ball.Intersect(&l)
+	require.NotNil(t, inter)
+	require.InDelta(t, 4, inter.SignedDist, 1e-3)
+	require.InDeltaSlice(t, sl(I.Neg()), sl(inter.Normal), 1e-3)
+	miss := NewLine(Vector{-5, 0, 3}, I)
+	require.Nil(t, ball.Intersect(&miss))
+
+	// the limits stop the tracing
+	short, err := NewSDF(SphereSDF(1).Move(K), WithSDFSteps(256, 3))
+	require.NoError(t, err)
+	require.Nil(t, short.Intersect(&l))
+	bounded, err := NewSDF(SphereSDF(1).Repeat(Vector{4, 4, 4}), WithSDFBounds(Box{Vector{-2, -2, -2}, Vector{2, 2, 2}}))
+	require.NoError(t, err)
+	l = NewLine(Vector{-10, 0, 0}, I)
+	inter = bounded.Intersect(&l)
+	require.NotNil(t, inter)
+	require.InDelta(t, 9, inter.SignedDist, 1e-3)
+	l = NewLine(Vector{4, 0, -10}, K)
+	require.Nil(t, bounded.Intersect(&l))
+
+	// the sponge tunnels go through
+	sponge, err := NewSDF(MengerSDF(1, 2), WithSDFBounds(Box{Vector{-1, -1, -1}, Vector{1, 1, 1}}))
+	require.NoError(t, err)
+	l = NewLine(Vector{-5, 0, 0}, I)
+	require.Nil(t, sponge.Intersect(&l))
+	l = NewLine(Vector{-5, 0.5, 0.5}, I)
+	require.NotNil(t, sponge.Intersect(&l))
+
+	// instances of SDFs without bounds are traced like the SDF
+	in, err := NewInstance(&ball, Translation(J.Mul(2)))
+	require.NoError(t, err)
+	require.True(t, in.Bounds().Empty())
+	l = NewLine(Vector{-5, 2, 1}, I)
+	inter = in.Intersect(&l)
+	require.NotNil(t, inter)
+	require.InDelta(t, 4, inter.SignedDist, 1e-3)
+}
+
+func TestSDFEngine(t *testing.T) {
+	// SDFs and triangles are picked and hide each other by distance
+	engine := NewEngine()
+	engine.RepositionCamera(func(f Frame) Frame {
+		return f.Move(I.Mul(-5))
+	})
+	cube, err := NewCube(1, 1, 1)
+	require.NoError(t, err)
+	ball, err := NewSDF(SphereSDF(1).SmoothUnion(SphereSDF(0.5).Move(J.Mul(1.5)), 0.2).Move(J.Mul(3)))
+	require.NoError(t, err)
+	hs := engine.Add(&cube, &ball)
+
+	picked, inter := engine.Pick(50, 50, 101, 1)
+	require.Equal(t, hs[0], picked)
+	require.InDelta(t, 4.5, inter.SignedDist, 1e-2)
+	engine.Place(hs[1], func(f Frame) Frame { return f.Move(J.Mul(-3).Sub(I)) })
+	picked, inter = engine.Pick(50, 50, 101, 1)
+	require.Equal(t, hs[1], picked)
+	require.InDelta(t, 3, inter.SignedDist, 1e-2)
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"slices"
+)
+
+// PNGSequence returns a frame callback for RenderAnimation saving every frame
+// as a PNG file named after pattern formatted with the frame index, like "frame%04d.png"
+func PNGSequence(pattern string) func(idx int, img *image.RGBA) error {
+	return func(idx int, img *image.RGBA) error {
+		f, err := os.Create(fmt.Sprintf(pattern, idx))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := png.Encode(f, img); err != nil {
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// GIFEncoder collects frames in an animated GIF, its Frame method
+// is a frame callback for RenderAnimation
+type GIFEncoder struct {
+	// delay is the time between frames in 100ths of second
+	delay int
+	anim  gif.GIF
+}
+
+// NewGIFEncoder returns an encoder showing fps frames per second,
+// the animation plays loops times, 0 meaning forever.
+// GIF delays are in 100ths of second so the frame rate is rounded.
+func NewGIFEncoder(fps float64, loops int) *GIFEncoder {
+	ge := &GIFEncoder{delay: max(1, int(math.Round(100/fps)))}
+	switch {
+	case loops == 1:
+		ge.anim.LoopCount = -1
+	case loops > 1:
+		// NOTE(@lberg): the GIF loop count is the number of repetitions after the first play
+		ge.anim.LoopCount = loops - 1
+	}
+	return ge
+}
+
+// Frame quantizes img to its own palette and appends it to the animation
+func (ge *GIFEncoder) Frame(_ int, img *image.RGBA) error {
+	ge.anim.Image = append(ge.anim.Image, quantize(img))
+	ge.anim.Delay = append(ge.anim.Delay, ge.delay)
+	return nil
+}
+
+func (ge *GIFEncoder) Encode(w io.Writer) error {
+	if len(ge.anim.Image) == 0 {
+		return fmt.Errorf("gif without frames")
+	}
+	return gif.EncodeAll(w, &ge.anim)
+}
+
+// quantize maps img to the 256 most used colors, after reducing the channels to 5 bits.
+// Renders are mostly flat areas so there is no need for dithering, which would flicker.
+func quantize(img *image.RGBA) *image.Paletted {
+	key := func(c color.RGBA) uint32 {
+		return uint32(c.R>>3)<<15 | uint32(c.G>>3)<<10 | uint32(c.B>>3)<<5 | uint32(c.A>>3)
+	}
+	type bin struct {
+		count      int
+		r, g, b, a int
+	}
+	bins := make(map[uint32]*bin)
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			bn := bins[key(c)]
+			if bn == nil {
+				bn = &bin{}
+				bins[key(c)] = bn
+			}
+			bn.count++
+			bn.r, bn.g, bn.b, bn.a = bn.r+int(c.R), bn.g+int(c.G), bn.b+int(c.B), bn.a+int(c.A)
+		}
+	}
+	keys := make([]uint32, 0, len(bins))
+	for k := range bins {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(k0, k1 uint32) int {
+		if d := bins[k1].count - bins[k0].count; d != 0 {
+			return d
+		}
+		return int(k0) - int(k1)
+	})
+	keys = keys[:min(len(keys), 256)]
+
+	// every bin is painted with the average of its colors
+	pal := make(color.Palette, len(keys))
+	for idx, k := range keys {
+		bn := bins[k]
+		pal[idx] = color.RGBA{uint8(bn.r / bn.count), uint8(bn.g / bn.count), uint8(bn.b / bn.count), uint8(bn.a / bn.count)}
+	}
+	index := make(map[uint32]uint8, len(bins))
+	for idx, k := range keys {
+		index[k] = uint8(idx)
+	}
+	out := image.NewPaletted(b, pal)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			idx, ok := index[key(c)]
+			if !ok {
+				// NOTE(@lberg): rare colors fall back to the closest one of the palette
+				idx = uint8(pal.Index(c))
+				index[key(c)] = idx
+			}
+			out.SetColorIndex(x, y, idx)
+		}
+	}
+	return out
+}
@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// Interval is a part of a line inside a solid, from the surface crossed
+// to get in to the one crossed to get out
+type Interval struct {
+	In, Out *Intersection
+}
+
+// Solid is implemented by closed renderables, which have an inside
+type Solid interface {
+	Renderable
+	// Intervals returns the parts of the whole line inside the solid,
+	// sorted along the line and not overlapping.
+	// Distances are negative behind the origin of the line.
+	Intervals(l *Line) []Interval
+}
+
+// nearest returns the first crossing in front of the origin of the line,
+// the exit if the origin is inside
+func nearest(ivs []Interval) *Intersection {
+	for _, iv := range ivs {
+		for _, inter := range []*Intersection{iv.In, iv.Out} {
+			if inter.SignedDist > 0 {
+				return inter
+			}
+		}
+	}
+	return nil
+}
+
+// crossing builds the intersection at t along l, with the normal facing the origin of the line
+func crossing(l *Line, t float64, normal Vector) *Intersection {
+	p := l.P.Add(l.Dir.Mul(t))
+	dist := p.Sub(l.P).Norm()
+	if t < 0 {
+		dist *= -1
+	}
+	if normal.Dot(l.Dir) > 0 {
+		normal = normal.Neg()
+	}
+	return &Intersection{IntPoint: p, SignedDist: dist, Normal: normal, Where: inside}
+}
+
+type Sphere struct {
+	Center Vector
+	Radius float64
+	color  color.Color
+	IDGen
+}
+
+type sphereOption func(*Sphere)
+
+func WithSphereColor(c color.Color) sphereOption {
+	return func(s *Sphere) {
+		s.color = c
+	}
+}
+
+func NewSphere(center Vector, radius float64, opts ...sphereOption) (Sphere, error) {
+	if radius <= 0 {
+		return Sphere{}, fmt.Errorf("degenerate sphere")
+	}
+	s := Sphere{Center: center, Radius: radius, color: color.RGBA{255, 0, 0, 255}}
+	for _, op := range opts {
+		op(&s)
+	}
+	return s, nil
+}
+
+func (s *Sphere) Intervals(l *Line) []Interval {
+	o := l.P.Sub(s.Center)
+	a, b, c := l.Dir.Dot(l.Dir), 2*o.Dot(l.Dir), o.Dot(o)-s.Radius*s.Radius
+	delta := b*b - 4*a*c
+	if delta < 0 {
+		return nil
+	}
+	sq := math.Sqrt(delta)
+	in, out := (-b-sq)/(2*a), (-b+sq)/(2*a)
+	iv := Interval{
+		In:  crossing(l, in, l.P.Add(l.Dir.Mul(in)).Sub(s.Center).Normalize()),
+		Out: crossing(l, out, l.P.Add(l.Dir.Mul(out)).Sub(s.Center).Normalize()),
+	}
+	iv.In.Color, iv.Out.Color = s.color, s.color
+	return []Interval{iv}
+}
+
+func (s *Sphere) Intersect(l *Line) *Intersection {
+	return nearest(s.Intervals(l))
+}
+
+func (s *Sphere) Color() color.Color {
+	return s.color
+}
+
+func (s *Sphere) Bounds() Box {
+	r := Vector{s.Radius, s.Radius, s.Radius}
+	return Box{s.Center.Sub(r), s.Center.Add(r)}
+}
+
+// Cylinder is a closed cylinder, its axis goes from the centre of one cap to the other
+type Cylinder struct {
+	P0, P1    Vector
+	Radius    float64
+	color     color.Color
+	edgeColor *color.Color
+	IDGen
+}
+
+type cylinderOption func(*Cylinder)
+
+func WithCylinderColor(c color.Color) cylinderOption {
+	return func(cy *Cylinder) {
+		cy.color = c
+	}
+}
+
+// WithCylinderEdgeColor paints the rims of the caps
+func WithCylinderEdgeColor(c color.Color) cylinderOption {
+	return func(cy *Cylinder) {
+		cy.edgeColor = &c
+	}
+}
+
+func NewCylinder(p0, p1 Vector, radius float64, opts ...cylinderOption) (Cylinder, error) {
+	if radius <= 0 || p0.Sub(p1).Norm() < Eps {
+		return Cylinder{}, fmt.Errorf("degenerate cylinder")
+	}
+	cy := Cylinder{P0: p0, P1: p1, Radius: radius, color: color.RGBA{255, 0, 0, 255}}
+	for _, op := range opts {
+		op(&cy)
+	}
+	return cy, nil
+}
+
+func (cy *Cylinder) Intervals(l *Line) []Interval {
+	axis := cy.P1.Sub(cy.P0)
+	h := axis.Norm()
+	u := axis.Mul(1 / h)
+	o := l.P.Sub(cy.P0)
+	// NOTE(@lberg): the line is inside between the caps and within the radius from the axis,
+	// the interval is where both hold
+	tMin, tMax := math.Inf(-1), math.Inf(1)
+	nMin, nMax := Zero, Zero
+	du, ou := l.Dir.Dot(u), o.Dot(u)
+	if du == 0 {
+		if ou < 0 || ou > h {
+			return nil
+		}
+	} else {
+		t0, t1 := -ou/du, (h-ou)/du
+		n0, n1 := u.Neg(), u
+		if t0 > t1 {
+			t0, t1, n0, n1 = t1, t0, n1, n0
+		}
+		tMin, tMax, nMin, nMax = t0, t1, n0, n1
+	}
+	dp, op := l.Dir.Sub(u.Mul(du)), o.Sub(u.Mul(ou))
+	a, b, c := dp.Dot(dp), 2*dp.Dot(op), op.Dot(op)-cy.Radius*cy.Radius
+	if a < Eps*Eps {
+		if c > 0 {
+			return nil
+		}
+	} else {
+		delta := b*b - 4*a*c
+		if delta < 0 {
+			return nil
+		}
+		sq := math.Sqrt(delta)
+		t0, t1 := (-b-sq)/(2*a), (-b+sq)/(2*a)
+		radial := func(t float64) Vector {
+			p := op.Add(dp.Mul(t))
+			return p.Normalize()
+		}
+		if t0 > tMin {
+			tMin, nMin = t0, radial(t0)
+		}
+		if t1 < tMax {
+			tMax, nMax = t1, radial(t1)
+		}
+	}
+	if tMin > tMax {
+		return nil
+	}
+	iv := Interval{In: cy.crossing(l, tMin, nMin), Out: cy.crossing(l, tMax, nMax)}
+	return []Interval{iv}
+}
+
+// crossing is the intersection at t along l, painting the rims of the caps with the edge color
+func (cy *Cylinder) crossing(l *Line, t float64, normal Vector) *Intersection {
+	inter := crossing(l, t, normal)
+	inter.Color = cy.color
+	axis := cy.P1.Sub(cy.P0)
+	h := axis.Norm()
+	p := inter.IntPoint.Sub(cy.P0)
+	s := p.Dot(axis) / h
+	r := p.Sub(axis.Mul(s / h)).Norm()
+	tol := 3e-3 * max(cy.Radius, h)
+	if (s < tol || s > h-tol) && r > cy.Radius-tol {
+		inter.Where = edge
+		if cy.edgeColor != nil {
+			inter.Color = *cy.edgeColor
+		}
+	}
+	return inter
+}
+
+func (cy *Cylinder) Intersect(l *Line) *Intersection {
+	return nearest(cy.Intervals(l))
+}
+
+func (cy *Cylinder) Color() color.Color {
+	return cy.color
+}
+
+// Bounds returns the box around the caps
+func (cy *Cylinder) Bounds() Box {
+	axis := cy.P1.Sub(cy.P0).Normalize()
+	// NOTE(@lberg): a cap extends along each axis by the radius times the sine
+	// of the angle between that axis and the cylinder one
+	ext := Vector{
+		cy.Radius * math.Sqrt(max(0, 1-axis.X*axis.X)),
+		cy.Radius * math.Sqrt(max(0, 1-axis.Y*axis.Y)),
+		cy.Radius * math.Sqrt(max(0, 1-axis.Z*axis.Z)),
+	}
+	return EmptyBox().Extend(cy.P0.Sub(ext), cy.P0.Add(ext), cy.P1.Sub(ext), cy.P1.Add(ext))
+}
@@ -0,0 +1,299 @@
+package internal
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+)
+
+// EdgeKind selects the edges of a line drawing, kinds can be combined
+type EdgeKind int
+
+const (
+	// SilhouetteEdges are where a surface turns away from the camera, outlining the mesh
+	SilhouetteEdges EdgeKind = 1 << iota
+	// CreaseEdges are where the surface folds by more than SVGOptions.CreaseAngle
+	CreaseEdges
+	// BoundaryEdges belong to a single triangle, like the sides of a lone quad
+	BoundaryEdges
+
+	AllEdges = SilhouetteEdges | CreaseEdges | BoundaryEdges
+)
+
+func (k EdgeKind) String() string {
+	switch k {
+	case SilhouetteEdges:
+		return "silhouette"
+	case CreaseEdges:
+		return "crease"
+	case BoundaryEdges:
+		return "boundary"
+	}
+	return "edges"
+}
+
+// StrokeStyle is the way lines are drawn
+type StrokeStyle struct {
+	// Color is black when nil
+	Color color.Color
+	// Width is in pixels, 1 when 0
+	Width float64
+	// Dash is the lengths of the dashes and of the gaps between them in pixels,
+	// lines are solid when empty
+	Dash []float64
+}
+
+// SVGOptions configures a line drawing, the zero value draws all the visible edges in black
+type SVGOptions struct {
+	// Edges are the kinds of edges drawn, AllEdges when 0
+	Edges EdgeKind
+	// CreaseAngle is the smallest fold of a crease, 30 degrees when 0
+	CreaseAngle Radian
+	Style       StrokeStyle
+	// Styles replaces Style for some objects, by their handle for Engine.WriteSVG
+	// or their id for Camera.WriteSVG
+	Styles map[Handle]StrokeStyle
+	// Hidden draws the hidden parts of the edges with this style, they are removed when nil
+	Hidden *StrokeStyle
+}
+
+// lineEdge is an edge of a mesh in world coordinates with its kinds
+type lineEdge struct {
+	p0, p1 Vector
+	kinds  EdgeKind
+}
+
+// lineEdges returns the edges of the triangles of a mesh seen from eye, each edge once
+// and in the order of the triangles. Edges inside flat faces have no kind.
+func lineEdges(tris []Triangle, eye Vector, crease Radian) []lineEdge {
+	type edge struct {
+		p0, p1 Vector
+		// sides are the vertices opposite to the edge of the triangles sharing it
+		sides []Vector
+	}
+	var order []edgeKey
+	edges := make(map[edgeKey]*edge)
+	for idx := range tris {
+		vs := [3]Vector{tris[idx].P0, tris[idx].P1, tris[idx].P2}
+		for e := range 3 {
+			p0, p1 := vs[(e+1)%3], vs[(e+2)%3]
+			k := newEdgeKey(p0, p1)
+			if edges[k] == nil {
+				edges[k] = &edge{p0: p0, p1: p1}
+				order = append(order, k)
+			}
+			edges[k].sides = append(edges[k].sides, vs[e])
+		}
+	}
+
+	res := make([]lineEdge, 0, len(order))
+	for _, k := range order {
+		e := edges[k]
+		le := lineEdge{p0: e.p0, p1: e.p1}
+		switch len(e.sides) {
+		case 1:
+			le.kinds = BoundaryEdges
+		case 2:
+			// NOTE(@lberg): triangles may wind either way, so faces are compared
+			// through their vertices opposite to the edge rather than their normals
+			dir := e.p1.Sub(e.p0).Normalize()
+			perp := func(o Vector) Vector {
+				v := o.Sub(e.p0)
+				return v.Sub(dir.Mul(v.Dot(dir))).Normalize()
+			}
+			u0, u1 := perp(e.sides[0]), perp(e.sides[1])
+			dihedral := math.Acos(max(-1, min(1, u0.Dot(u1))))
+			if math.Pi-dihedral > float64(crease) {
+				le.kinds |= CreaseEdges
+			}
+			// both faces on the same side of the plane through the eye and the edge
+			m := e.p0.Sub(eye).Cross(e.p1.Sub(eye))
+			if m.Dot(e.sides[0].Sub(eye))*m.Dot(e.sides[1].Sub(eye)) > 0 {
+				le.kinds |= SilhouetteEdges
+			}
+		default:
+			le.kinds = CreaseEdges
+		}
+		res = append(res, le)
+	}
+	return res
+}
+
+// lineRun is a part of an edge, from t0 to t1 along it, all visible or all hidden
+type lineRun struct {
+	t0, t1  float64
+	visible bool
+}
+
+// visibility splits the segment p0, p1 seen from eye in visible and hidden runs,
+// checking samples points along it against objs
+func visibility(p0, p1, eye Vector, samples int, objs []Renderable) []lineRun {
+	visible := func(t float64) bool {
+		q := p0.Add(p1.Sub(p0).Mul(t))
+		dist := q.Sub(eye).Norm()
+		l := NewLine(eye, q.Sub(eye))
+		inter, _ := trace(&l, objs)
+		// NOTE(@lberg): edges lie on the surfaces they bound, which are hit at about their distance
+		return inter == nil || inter.SignedDist >= dist*(1-1e-4)
+	}
+	runs := []lineRun{{0, 0, visible(0)}}
+	for idx := 1; idx <= samples; idx++ {
+		t := float64(idx) / float64(samples)
+		last := &runs[len(runs)-1]
+		if v := visible(t); v != last.visible {
+			// bisect the change between the samples
+			lo, hi := last.t1, t
+			for range 20 {
+				mid := (lo + hi) / 2
+				if visible(mid) == last.visible {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			last.t1 = lo
+			runs = append(runs, lineRun{hi, t, v})
+			continue
+		}
+		last.t1 = t
+	}
+	return runs
+}
+
+// WriteSVG draws the edges of the meshes in objs as SVG paths, the hidden parts removed.
+// The drawing matches the images of RenderPerspective with the same size.
+// Renderables which are not meshes have no edges but hide the ones behind them.
+func (c *Camera) WriteSVG(w io.Writer, width int, ratio float64, objs []Renderable, opts SVGOptions) error {
+	s := c.screen(width, ratio)
+	if opts.Edges == 0 {
+		opts.Edges = AllEdges
+	}
+	if opts.CreaseAngle == 0 {
+		opts.CreaseAngle = DegToRad(30)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		s.width, s.height, s.width, s.height)
+	for _, obj := range objs {
+		m, ok := obj.(Mesh)
+		if !ok {
+			continue
+		}
+		tris := m.Triangles()
+		if len(tris) == 0 {
+			continue
+		}
+		style, ok := opts.Styles[Handle(obj.ID())]
+		if !ok {
+			style = opts.Style
+		}
+		// paths by class, in a fixed order
+		paths := map[string]*strings.Builder{}
+		classes := []string{SilhouetteEdges.String(), CreaseEdges.String(), BoundaryEdges.String(), "hidden"}
+		for _, class := range classes {
+			paths[class] = &strings.Builder{}
+		}
+		for _, e := range lineEdges(tris, c.F.P, opts.CreaseAngle) {
+			kinds := e.kinds & opts.Edges
+			if kinds == 0 {
+				continue
+			}
+			class := BoundaryEdges.String()
+			if kinds&SilhouetteEdges != 0 {
+				class = SilhouetteEdges.String()
+			} else if kinds&CreaseEdges != 0 {
+				class = CreaseEdges.String()
+			}
+			p0, p1, ok := clipNear(c.F, e.p0, e.p1)
+			if !ok {
+				continue
+			}
+			x0, y0, _ := s.project(c.F, p0)
+			x1, y1, _ := s.project(c.F, p1)
+			samples := min(max(int(math.Hypot(x1-x0, y1-y0)/2), 2), 1000)
+			for _, run := range visibility(p0, p1, c.F.P, samples, objs) {
+				// NOTE(@lberg): pixel (x, y) is sampled at its top left corner,
+				// which is the centre of the pixel in the drawing
+				ax, ay, _ := s.project(c.F, p0.Add(p1.Sub(p0).Mul(run.t0)))
+				zx, zy, _ := s.project(c.F, p0.Add(p1.Sub(p0).Mul(run.t1)))
+				// runs shorter than a pixel are where edges meet their occluders
+				if math.Hypot(zx-ax, zy-ay) < 0.5 {
+					continue
+				}
+				path := paths[class]
+				if !run.visible {
+					if opts.Hidden == nil {
+						continue
+					}
+					path = paths["hidden"]
+				}
+				fmt.Fprintf(path, "M%.2f %.2fL%.2f %.2f", ax+0.5, ay+0.5, zx+0.5, zy+0.5)
+			}
+		}
+
+		b.WriteString(`<g id="`)
+		if err := xml.EscapeText(&b, []byte(obj.ID())); err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, `" fill="none" stroke-linecap="round"%s>`+"\n", strokeAttrs(style))
+		for _, class := range classes {
+			if paths[class].Len() == 0 {
+				continue
+			}
+			attrs := ""
+			if class == "hidden" {
+				attrs = strokeAttrs(*opts.Hidden)
+			}
+			fmt.Fprintf(&b, `<path class="%s"%s d="%s"/>`+"\n", class, attrs, paths[class].String())
+		}
+		b.WriteString("</g>\n")
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// clipNear returns the part of the segment p0, p1 in front of the image plane
+// of a camera in cam, false if there is none
+func clipNear(cam Frame, p0, p1 Vector) (Vector, Vector, bool) {
+	d0 := p0.Sub(cam.P).Dot(cam.I) - focDis
+	d1 := p1.Sub(cam.P).Dot(cam.I) - focDis
+	switch {
+	case d0 < 0 && d1 < 0:
+		return p0, p1, false
+	case d0 < 0:
+		p0 = p0.Add(p1.Sub(p0).Mul(d0 / (d0 - d1)))
+	case d1 < 0:
+		p1 = p1.Add(p0.Sub(p1).Mul(d1 / (d1 - d0)))
+	}
+	return p0, p1, true
+}
+
+// strokeAttrs returns the SVG attributes of style
+func strokeAttrs(style StrokeStyle) string {
+	c := style.Color
+	if c == nil {
+		c = color.Black
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	width := style.Width
+	if width <= 0 {
+		width = 1
+	}
+	attrs := fmt.Sprintf(` stroke="#%02x%02x%02x" stroke-width="%g"`, n.R, n.G, n.B, width)
+	if n.A != 255 {
+		attrs += fmt.Sprintf(` stroke-opacity="%.2g"`, float64(n.A)/255)
+	}
+	if len(style.Dash) > 0 {
+		dash := make([]string, len(style.Dash))
+		for idx, d := range style.Dash {
+			dash[idx] = fmt.Sprintf("%g", d)
+		}
+		attrs += fmt.Sprintf(` stroke-dasharray="%s"`, strings.Join(dash, " "))
+	}
+	return attrs
+}
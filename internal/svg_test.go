@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"bytes"
+	"image/color"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// svgSegments counts the segments of the paths of the given class in svg
+func svgSegments(svg, class string) int {
+	n := 0
+	re := regexp.MustCompile(`<path class="` + class + `"[^>]* d="([^"]*)"`)
+	for _, m := range re.FindAllStringSubmatch(svg, -1) {
+		n += strings.Count(m[1], "M")
+	}
+	return n
+}
+
+func TestSVG(t *testing.T) {
+	engine := NewEngine()
+	engine.RepositionCamera(func(Frame) Frame {
+		return LookAt(Vector{-4, -3, 2}, Zero, K)
+	})
+	cube, err := NewCube(1, 1, 1)
+	require.NoError(t, err)
+	h := engine.Add(&cube)[0]
+
+	// a cube seen from a corner shows 6 silhouette edges and 3 creases, the other 3 are hidden
+	var buf bytes.Buffer
+	require.NoError(t, engine.WriteSVG(&buf, 200, 1, SVGOptions{Hidden: &StrokeStyle{Dash: []float64{4, 2}}}))
+	svg := buf.String()
+	require.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="200" height="200"`))
+	require.Equal(t, 6, svgSegments(svg, "silhouette"), svg)
+	require.Equal(t, 3, svgSegments(svg, "crease"), svg)
+	require.Equal(t, 3, svgSegments(svg, "hidden"), svg)
+	require.Contains(t, svg, `stroke-dasharray="4 2"`)
+	require.Contains(t, svg, `<g id="`+string(h)+`" fill="none" stroke-linecap="round" stroke="#000000" stroke-width="1">`)
+
+	// kinds are selectable, hidden parts removed by default
+	buf.Reset()
+	require.NoError(t, engine.WriteSVG(&buf, 200, 1, SVGOptions{Edges: SilhouetteEdges}))
+	svg = buf.String()
+	require.Equal(t, 6, svgSegments(svg, "silhouette"), svg)
+	require.Zero(t, svgSegments(svg, "crease"))
+	require.Zero(t, svgSegments(svg, "hidden"))
+
+	// a plate in front cuts the edges behind it and gets its own style
+	plate, err := NewQuad(Vector{-2, -2, 0.9}, Vector{-2, 2, 0.9}, Vector{-2, -2, 1.1}, Vector{-2, 2, 1.1},
+		WithQuadColor(color.White))
+	require.NoError(t, err)
+	hp := engine.Add(&plate)[0]
+	buf.Reset()
+	require.NoError(t, engine.WriteSVG(&buf, 200, 1, SVGOptions{
+		Styles: map[Handle]StrokeStyle{hp: {Color: color.NRGBA{255, 0, 0, 128}, Width: 2}},
+	}))
+	svg = buf.String()
+	require.Contains(t, svg, `stroke="#ff0000" stroke-width="2" stroke-opacity="0.5"`)
+	require.Equal(t, 4, svgSegments(svg, "boundary"), svg)
+	// the plate cuts the left silhouette in two and hides the bottom of the right one
+	require.Equal(t, 7, svgSegments(svg, "silhouette"), svg)
+}
@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"image"
+	"slices"
+	"sync"
+)
+
+// TileOrder is the order in which the tiles of an image are rendered
+type TileOrder int
+
+const (
+	// ScanlineOrder renders tiles row by row from the top left
+	ScanlineOrder TileOrder = iota
+	// SpiralOrder walks a square spiral starting from the centre tile
+	SpiralOrder
+	// CenterOutOrder renders tiles by increasing distance from the image centre
+	CenterOutOrder
+)
+
+// tiles splits bounds in square tiles of the given size sorted by order,
+// tiles on the right and bottom borders may be smaller
+func tiles(bounds image.Rectangle, size int, order TileOrder) []image.Rectangle {
+	cols := (bounds.Dx() + size - 1) / size
+	rows := (bounds.Dy() + size - 1) / size
+	tile := func(col, row int) image.Rectangle {
+		min := bounds.Min.Add(image.Pt(col*size, row*size))
+		return image.Rectangle{min, min.Add(image.Pt(size, size))}.Intersect(bounds)
+	}
+
+	ts := make([]image.Rectangle, 0, cols*rows)
+	switch order {
+	case SpiralOrder:
+		// NOTE(@lberg): the spiral goes right, down, left, up with legs
+		// growing every two turns and may exit the grid on the longer side,
+		// so we keep walking until all the tiles have been visited
+		col, row := (cols-1)/2, (rows-1)/2
+		visit := func() {
+			if col >= 0 && col < cols && row >= 0 && row < rows {
+				ts = append(ts, tile(col, row))
+			}
+		}
+		visit()
+		dirs := []image.Point{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+		for leg := 0; len(ts) < cols*rows; leg++ {
+			dir := dirs[leg%4]
+			for range leg/2 + 1 {
+				col, row = col+dir.X, row+dir.Y
+				visit()
+			}
+		}
+	default:
+		for row := range rows {
+			for col := range cols {
+				ts = append(ts, tile(col, row))
+			}
+		}
+		if order == CenterOutOrder {
+			center := bounds.Min.Add(bounds.Max).Div(2)
+			dist := func(t image.Rectangle) int {
+				d := t.Min.Add(t.Max).Div(2).Sub(center)
+				return d.X*d.X + d.Y*d.Y
+			}
+			slices.SortStableFunc(ts, func(a, b image.Rectangle) int {
+				return cmp.Compare(dist(a), dist(b))
+			})
+		}
+	}
+	return ts
+}
+
+// renderTiles calls fn on every tile using the given number of workers.
+// It stops at the first error, which is returned, or when ctx is done.
+// Panics in fn are turned into errors as well.
+func renderTiles(ctx context.Context, ts []image.Rectangle, workers int, fn func(ctx context.Context, tile image.Rectangle) error) error {
+	tileCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var errOnce sync.Once
+	var tileErr error
+	fail := func(err error) {
+		errOnce.Do(func() {
+			tileErr = err
+			cancel()
+		})
+	}
+	run := func(tile image.Rectangle) {
+		defer func() {
+			if r := recover(); r != nil {
+				fail(fmt.Errorf("tile %v: %v", tile, r))
+			}
+		}()
+		if err := fn(tileCtx, tile); err != nil {
+			fail(err)
+		}
+	}
+
+	jobs := make(chan image.Rectangle)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tile := range jobs {
+				run(tile)
+			}
+		}()
+	}
+feed:
+	for _, tile := range ts {
+		select {
+		case jobs <- tile:
+		case <-tileCtx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if tileErr != nil {
+		return tileErr
+	}
+	return ctx.Err()
+}
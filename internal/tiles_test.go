@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTilesCover(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 37)
+	for _, order := range []TileOrder{ScanlineOrder, SpiralOrder, CenterOutOrder} {
+		ts := tiles(bounds, 16, order)
+		require.Len(t, ts, 7*3)
+		covered := make(map[image.Point]int)
+		for _, tile := range ts {
+			require.True(t, tile.In(bounds))
+			for y := tile.Min.Y; y < tile.Max.Y; y++ {
+				for x := tile.Min.X; x < tile.Max.X; x++ {
+					covered[image.Pt(x, y)]++
+				}
+			}
+		}
+		require.Len(t, covered, 100*37)
+		for _, n := range covered {
+			require.Equal(t, 1, n)
+		}
+	}
+	// both centred orders start from the middle
+	require.Equal(t, image.Rect(48, 16, 64, 32), tiles(bounds, 16, SpiralOrder)[0])
+	require.Equal(t, image.Rect(48, 16, 64, 32), tiles(bounds, 16, CenterOutOrder)[0])
+}
+
+func TestRenderTilesErrors(t *testing.T) {
+	ts := tiles(image.Rect(0, 0, 256, 256), 8, ScanlineOrder)
+	boom := errors.New("boom")
+	var done atomic.Int32
+	err := renderTiles(context.Background(), ts, 4, func(ctx context.Context, tile image.Rectangle) error {
+		if tile.Min == image.Pt(64, 64) {
+			return boom
+		}
+		done.Add(1)
+		return nil
+	})
+	require.ErrorIs(t, err, boom)
+	// the error stops the scheduling of the remaining tiles
+	require.Less(t, int(done.Load()), len(ts)-1)
+
+	err = renderTiles(context.Background(), ts, 4, func(ctx context.Context, tile image.Rectangle) error {
+		panic("oops")
+	})
+	require.ErrorContains(t, err, "oops")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	engine := NewEngine()
+	_, err = engine.Render(ctx, 64, 1)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestProgressiveRender(t *testing.T) {
+	engine := NewEngine()
+	engine.RepositionCamera(func(f Frame) Frame {
+		return f.Move(I.Mul(-3))
+	})
+	cube, err := NewCube(1, 1, 1)
+	require.NoError(t, err)
+	engine.Add(&cube)
+
+	full, err := engine.Render(context.Background(), 50, 1)
+	require.NoError(t, err)
+
+	var passes []int
+	progressive, err := engine.Render(context.Background(), 50, 1, WithTileSize(12),
+		WithProgress(10, func(img *image.RGBA, pass, total int) bool {
+			require.Equal(t, 4, total)
+			passes = append(passes, pass)
+			return true
+		}))
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3, 4}, passes)
+	// the last pass fills in every pixel exactly as a single pass render
+	require.Equal(t, full.Pix, progressive.Pix)
+
+	// stopping after the first pass returns the coarse image
+	passes = nil
+	coarse, err := engine.Render(context.Background(), 50, 1,
+		WithProgress(8, func(img *image.RGBA, pass, total int) bool {
+			passes = append(passes, pass)
+			return false
+		}))
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, passes)
+	require.Equal(t, full.RGBAAt(24, 24), coarse.RGBAAt(24, 24))
+	require.NotEqual(t, full.Pix, coarse.Pix)
+
+	// the first pass fills every pixel, whatever the tile size
+	wall, err := NewQuad(Vector{1, -10, -10}, Vector{1, 10, -10}, Vector{1, -10, 10}, Vector{1, 10, 10},
+		WithQuadColor(color.White))
+	require.NoError(t, err)
+	engine.Add(&wall)
+	_, err = engine.Render(context.Background(), 40, 1, WithTileSize(10),
+		WithProgress(8, func(img *image.RGBA, pass, total int) bool {
+			for idx := 3; idx < len(img.Pix); idx += 4 {
+				require.EqualValues(t, 255, img.Pix[idx], "pixel %d", idx/4)
+			}
+			return false
+		}))
+	require.NoError(t, err)
+}
@@ -0,0 +1,71 @@
+package internal
+
+import "fmt"
+
+// ToneMapping compresses linear values in [0, inf) to the displayable [0, 1]
+type ToneMapping int
+
+const (
+	// ClampToneMapping cuts everything above 1
+	ClampToneMapping ToneMapping = iota
+	// ReinhardToneMapping is x / (1 + x)
+	ReinhardToneMapping
+	// ACESToneMapping is the Narkowicz fit of the ACES filmic curve
+	ACESToneMapping
+	// HableToneMapping is the Uncharted 2 filmic curve by John Hable
+	HableToneMapping
+)
+
+// maxToneInput is mapped to 1 by all the tone mappings and bounds their input
+const maxToneInput = 1e6
+
+// hableWhite is the linear value mapped to 1 by HableToneMapping
+const hableWhite = 11.2
+
+func hable(x float32) float32 {
+	const a, b, c, d, e, f = 0.15, 0.50, 0.10, 0.20, 0.02, 0.30
+	return (x*(a*x+c*b)+d*e)/(x*(a*x+b)+d*f) - e/f
+}
+
+// Map tone maps a single channel
+func (tm ToneMapping) Map(x float32) float32 {
+	if !(x > 0) {
+		return 0
+	}
+	// NOTE(@lberg): the curves square x, huge values would overflow to inf/inf
+	x = min(x, maxToneInput)
+	switch tm {
+	case ReinhardToneMapping:
+		return x / (1 + x)
+	case ACESToneMapping:
+		return min(1, (x*(2.51*x+0.03))/(x*(2.43*x+0.59)+0.14))
+	case HableToneMapping:
+		// NOTE(@lberg): the curve is usually fed twice the exposure
+		return min(1, hable(2*x)/hable(hableWhite))
+	default:
+		return min(x, 1)
+	}
+}
+
+func (tm ToneMapping) String() string {
+	switch tm {
+	case ReinhardToneMapping:
+		return "reinhard"
+	case ACESToneMapping:
+		return "aces"
+	case HableToneMapping:
+		return "hable"
+	default:
+		return "clamp"
+	}
+}
+
+// ParseToneMapping is the inverse of ToneMapping.String
+func ParseToneMapping(s string) (ToneMapping, error) {
+	for _, tm := range []ToneMapping{ClampToneMapping, ReinhardToneMapping, ACESToneMapping, HableToneMapping} {
+		if tm.String() == s {
+			return tm, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown tone mapping %q", s)
+}
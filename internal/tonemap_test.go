@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSRGBRoundTrip(t *testing.T) {
+	for v := range 256 {
+		c := color.RGBA{uint8(v), uint8(255 - v), uint8(v / 2), 255}
+		fb := NewFramebuffer(image.Rect(0, 0, 1, 1))
+		fb.Set(0, 0, LinearFromColor(c))
+		require.Equal(t, c, fb.Resolve(ClampToneMapping, 0).RGBAAt(0, 0))
+	}
+	// mid grey is way darker than half in linear
+	require.InDelta(t, 0.216, LinearFromColor(color.Gray{128}).R, 1e-3)
+}
+
+func TestToneMapping(t *testing.T) {
+	for _, tm := range []ToneMapping{ClampToneMapping, ReinhardToneMapping, ACESToneMapping, HableToneMapping} {
+		require.InDelta(t, 0, tm.Map(0), 1e-6, tm.String())
+		prev := float32(0)
+		for x := float32(0.01); x < 100; x *= 1.1 {
+			v := tm.Map(x)
+			require.GreaterOrEqual(t, v, prev, tm.String())
+			require.LessOrEqual(t, v, float32(1), tm.String())
+			prev = v
+		}
+	}
+	require.Equal(t, float32(0.5), ClampToneMapping.Map(0.5))
+	require.Equal(t, float32(0.5), ReinhardToneMapping.Map(1))
+	require.InDelta(t, 1, HableToneMapping.Map(hableWhite/2), 1e-6)
+
+	// one stop of exposure doubles the linear value
+	fb := NewFramebuffer(image.Rect(0, 0, 1, 1))
+	fb.Set(0, 0, LinearColor{0.25, 0.25, 0.25, 1})
+	brighter := fb.Resolve(ClampToneMapping, 1).RGBAAt(0, 0)
+	fb.Set(0, 0, LinearColor{0.5, 0.5, 0.5, 1})
+	require.Equal(t, fb.Resolve(ClampToneMapping, 0).RGBAAt(0, 0), brighter)
+
+	// NaN and infinite samples or exposures resolve to black or white
+	nan, inf := float32(math.NaN()), float32(math.Inf(1))
+	fb = NewFramebuffer(image.Rect(0, 0, 3, 1))
+	fb.Set(0, 0, LinearColor{inf, nan, -inf, 1})
+	fb.Set(1, 0, LinearColor{1, 0.5, 0, nan})
+	fb.Set(2, 0, LinearColor{1e30, 1e-30, 0, 1})
+	for _, tm := range []ToneMapping{ClampToneMapping, ReinhardToneMapping, ACESToneMapping, HableToneMapping} {
+		for _, exposure := range []float64{-200, 0, 200, math.Inf(1), math.NaN()} {
+			img := fb.Resolve(tm, exposure)
+			require.Equal(t, color.RGBA{255, 0, 0, 255}, img.RGBAAt(0, 0), "%v %v", tm, exposure)
+			require.Equal(t, color.RGBA{}, img.RGBAAt(1, 0), "%v %v", tm, exposure)
+		}
+	}
+	require.Equal(t, float64(maxExposure), newRenderConfig(WithExposure(200)).exposure)
+	require.Equal(t, float64(-maxExposure), newRenderConfig(WithExposure(math.Inf(-1))).exposure)
+	require.Zero(t, newRenderConfig(WithExposure(math.NaN())).exposure)
+}
@@ -32,6 +32,9 @@ func newTrianglePlaneData(t *Triangle) trianglePlaneData {
 	return tpd
 }
 
+// edgeWidth is how close to 0 a barycentric coordinate is on an edge
+const edgeWidth = 3e-3
+
 type Triangle struct {
 	P0, P1, P2 Vector
 	IDGen
@@ -73,20 +76,19 @@ func NewTriangle(p0, p1, p2 Vector, opsf ...triangleOption) (Triangle, error) {
 }
 
 func (t Triangle) Move(v Vector) Triangle {
-	newT := t
-	newT.P0 = newT.P0.Add(v)
-	newT.P1 = newT.P1.Add(v)
-	newT.P2 = newT.P2.Add(v)
-	newT.planeData = newTrianglePlaneData(&newT)
-	newT.IDGen = IDGen{}
-	return newT
+	return t.Transform(Translation(v))
 }
 
 func (t Triangle) Rotate(axis Line, angle Radian) Triangle {
+	return t.Transform(RotationAround(axis, angle))
+}
+
+// Transform returns a copy of the triangle with the vertices transformed by m
+func (t Triangle) Transform(m Matrix4) Triangle {
 	newT := t
-	newT.P0 = newT.P0.Rotate(axis, angle)
-	newT.P1 = newT.P1.Rotate(axis, angle)
-	newT.P2 = newT.P2.Rotate(axis, angle)
+	newT.P0 = m.Apply(newT.P0)
+	newT.P1 = m.Apply(newT.P1)
+	newT.P2 = m.Apply(newT.P2)
 	newT.planeData = newTrianglePlaneData(&newT)
 	newT.IDGen = IDGen{}
 	return newT
@@ -113,7 +115,7 @@ func (t *Triangle) Intersect(l *Line) *Intersection {
 	barys := t.barycentric(&planeInter)
 	where := inside
 	for bc := range barys.Iter() {
-		if math.Abs(bc) <= 3e-3 {
+		if math.Abs(bc) <= edgeWidth {
 			if where == inside {
 				where = edge
 			} else {
@@ -135,10 +137,29 @@ func (t *Triangle) Intersect(l *Line) *Intersection {
 	if lineT < 0 {
 		distance *= -1
 	}
+	normal := t.planeData.plane.NormV
+	if normal.Dot(l.Dir) > 0 {
+		normal = normal.Neg()
+	}
 	return &Intersection{
 		IntPoint:   planeInter,
 		SignedDist: distance,
+		Normal:     normal,
 		Color:      color,
 		Where:      where,
+		base:       t.color,
 	}
 }
+
+// Triangles returns the triangle itself, making it a Mesh
+func (t *Triangle) Triangles() []Triangle {
+	return []Triangle{*t}
+}
+
+func (t *Triangle) Color() color.Color {
+	return t.color
+}
+
+func (t *Triangle) Bounds() Box {
+	return EmptyBox().Extend(t.P0, t.P1, t.P2)
+}
@@ -76,6 +76,18 @@ func (v *Vector) Slice() []float64 {
 	return slices.Collect(v.Iter())
 }
 
+// axis returns the X, Y or Z component for 0, 1 or 2
+func (v Vector) axis(idx int) float64 {
+	switch idx {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	default:
+		return v.Z
+	}
+}
+
 func (v Vector) Rotate(axis Line, angle Radian) Vector {
 	// move points so that the origin passes through the axis point
 	diff := axis.P.Neg()
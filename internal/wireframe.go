@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"image/color"
+	"math"
+)
+
+// HiddenEdges is how a wireframe draws the edges behind surfaces
+type HiddenEdges int
+
+const (
+	// RemoveHiddenEdges does not draw them
+	RemoveHiddenEdges HiddenEdges = iota
+	// DashHiddenEdges draws them dashed
+	DashHiddenEdges
+	// ShowHiddenEdges draws them like the visible ones
+	ShowHiddenEdges
+)
+
+// Wireframe configures the lines drawn by WithWireframe
+type Wireframe struct {
+	// Width is the width of the lines in pixels, whatever their distance, 1 when 0
+	Width float64
+	// Color of the lines, the edge color of the triangles (or their color) when nil
+	Color  color.Color
+	Hidden HiddenEdges
+	// Internal draws the edges shared by coplanar triangles, like the diagonals of quads
+	Internal bool
+	// Surfaces paints the surfaces under the lines, without their edge colors.
+	// Otherwise surfaces are transparent, they only hide the edges behind them.
+	Surfaces bool
+}
+
+// WithWireframe draws the edges of the meshes as lines, in place of the edge colors
+// of their triangles. Renderables which are not meshes have no lines but hide the ones behind.
+// Progressive renders draw them after every pass.
+func WithWireframe(w Wireframe) RenderOption {
+	return func(rc *renderConfig) {
+		rc.wireframe = &w
+	}
+}
+
+// wireEdge is an edge of a mesh in world coordinates
+type wireEdge struct {
+	p0, p1 Vector
+	color  color.Color
+}
+
+// edges returns the edges of the meshes in objs, each edge once
+func (w *Wireframe) edges(objs []Renderable) []wireEdge {
+	seen := make(map[edgeKey]bool)
+	var res []wireEdge
+	for _, obj := range objs {
+		m, ok := obj.(Mesh)
+		if !ok {
+			continue
+		}
+		tris := m.Triangles()
+		internal := internalEdges(tris)
+		for ti := range tris {
+			t := &tris[ti]
+			vs := [3]Vector{t.P0, t.P1, t.P2}
+			for e := range 3 {
+				if internal[ti][e] && !w.Internal {
+					continue
+				}
+				p0, p1 := vs[(e+1)%3], vs[(e+2)%3]
+				k := newEdgeKey(p0, p1)
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+				c := w.Color
+				if c == nil {
+					c = t.color
+					if t.edgeColor != nil {
+						c = *t.edgeColor
+					}
+				}
+				res = append(res, wireEdge{p0, p1, c})
+			}
+		}
+	}
+	return res
+}
+
+// draw draws the edges of objs on fb, depth has the depth along the camera I axis
+// of the closest surface in each pixel, +Inf where there is none
+func (w *Wireframe) draw(fb *Framebuffer, depth *Buffer[float64], cam Frame, s *screen, objs []Renderable) {
+	half := w.Width / 2
+	if w.Width <= 0 {
+		half = 0.5
+	}
+	dash := max(4, 6*half)
+	for _, edge := range w.edges(objs) {
+		// NOTE(@lberg): clip to the near plane before projecting, like the rasterizer
+		p0, p1, ok := clipNear(cam, edge.p0, edge.p1)
+		if !ok {
+			continue
+		}
+		x0, y0, w0 := s.project(cam, p0)
+		x1, y1, w1 := s.project(cam, p1)
+		dx, dy := x1-x0, y1-y0
+		length := math.Hypot(dx, dy)
+		col := LinearFromColor(edge.color)
+
+		minX := max(int(math.Floor(min(x0, x1)-half))-1, fb.Rect.Min.X)
+		maxX := min(int(math.Ceil(max(x0, x1)+half))+1, fb.Rect.Max.X-1)
+		minY := max(int(math.Floor(min(y0, y1)-half))-1, fb.Rect.Min.Y)
+		maxY := min(int(math.Ceil(max(y0, y1)+half))+1, fb.Rect.Max.Y-1)
+		for y := minY; y <= maxY; y++ {
+			for x := minX; x <= maxX; x++ {
+				// closest point of the segment, t along it
+				t := 0.
+				if length > 0 {
+					t = min(max(((float64(x)-x0)*dx+(float64(y)-y0)*dy)/(length*length), 0), 1)
+				}
+				cx, cy := x0+t*dx, y0+t*dy
+				cover := min(max(half+0.5-math.Hypot(float64(x)-cx, float64(y)-cy), 0), 1)
+				if cover == 0 {
+					continue
+				}
+				// the inverse of the depth is linear in the image
+				a := 1 / (w0 + t*(w1-w0))
+				if w.Hidden != ShowHiddenEdges && w.hidden(depth, s, cx, cy, a) {
+					if w.Hidden == RemoveHiddenEdges || math.Mod(t*length, 2*dash) >= dash {
+						continue
+					}
+				}
+				idx := fb.offset(x, y)
+				fb.Pix[idx] = col.Mul(float32(cover)).Add(fb.Pix[idx].Mul(float32(1 - cover)))
+			}
+		}
+	}
+}
+
+// hidden tells if a point of an edge at depth a, seen in (x, y), is behind a surface.
+// Edges lie on the surfaces they bound, so they are visible if any surface around
+// is about as far as them or farther.
+func (w *Wireframe) hidden(depth *Buffer[float64], s *screen, x, y, a float64) bool {
+	// NOTE(@lberg): a pixel covers this much at depth a, surfaces seen at grazing angles
+	// move by more than that from one pixel to the next
+	tol := 2*a*s.hOffset/focDis + 1e-6*a
+	px, py := int(math.Round(x)), int(math.Round(y))
+	for j := py - 1; j <= py+1; j++ {
+		for i := px - 1; i <= px+1; i++ {
+			if i < depth.Rect.Min.X || i >= depth.Rect.Max.X || j < depth.Rect.Min.Y || j >= depth.Rect.Max.Y {
+				return false
+			}
+			if depth.At(i, j) >= a-tol {
+				return false
+			}
+		}
+	}
+	return true
+}
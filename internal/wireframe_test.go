@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// square returns a square facing I at x with the given half side
+func square(t *testing.T, x, half float64, c color.Color) *Quad {
+	q, err := NewQuad(Vector{x, -half, -half}, Vector{x, half, -half}, Vector{x, -half, half}, Vector{x, half, half},
+		WithQuadColor(c), WithQuadEdgeColor(color.Black))
+	require.NoError(t, err)
+	return &q
+}
+
+// covered counts the pixels of row y drawn at all
+func covered(img *image.RGBA, y int) int {
+	n := 0
+	for x := range img.Rect.Dx() {
+		if img.RGBAAt(x, y).A > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestWireframeWidth(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+	for _, backend := range []Backend{RayTracingBackend, RasterBackend} {
+		for _, dist := range []float64{3, 12} {
+			engine := NewEngine()
+			engine.RepositionCamera(func(f Frame) Frame { return f.Move(I.Mul(-dist)) })
+			engine.Add(square(t, 0, 1, white))
+			img, err := engine.Render(context.Background(), 201, 1, WithBackend(backend),
+				WithWireframe(Wireframe{Width: 3, Color: white}))
+			require.NoError(t, err)
+			lines := img
+			// the row in the middle crosses the two vertical sides only, the diagonal is internal
+			require.InDelta(t, 6, covered(img, 100), 2, "%v at %v", backend, dist)
+			require.Zero(t, img.RGBAAt(100, 100).A)
+
+			img, err = engine.Render(context.Background(), 201, 1, WithBackend(backend),
+				WithWireframe(Wireframe{Width: 3, Color: white, Internal: true}))
+			require.NoError(t, err)
+			require.Equal(t, white, img.RGBAAt(100, 100))
+
+			// progressive renders have the lines at every pass, even when stopped early
+			passes := 0
+			img, err = engine.Render(context.Background(), 201, 1, WithBackend(backend),
+				WithWireframe(Wireframe{Width: 3, Color: white}),
+				WithProgress(8, func(img *image.RGBA, pass, _ int) bool {
+					require.InDelta(t, 6, covered(img, 100), 2, "%v at %v, pass %d", backend, dist, pass)
+					passes++
+					return pass < 2
+				}))
+			require.NoError(t, err)
+			require.Equal(t, 2, passes)
+			require.InDelta(t, 6, covered(img, 100), 2, "%v at %v", backend, dist)
+			img, err = engine.Render(context.Background(), 201, 1, WithBackend(backend),
+				WithWireframe(Wireframe{Width: 3, Color: white}),
+				WithProgress(8, func(*image.RGBA, int, int) bool { return true }))
+			require.NoError(t, err)
+			require.Equal(t, lines.Pix, img.Pix)
+		}
+	}
+}
+
+func TestWireframeHidden(t *testing.T) {
+	red, green := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}
+	engine := NewEngine()
+	engine.RepositionCamera(func(f Frame) Frame { return f.Move(I.Mul(-3)) })
+	// a small square hidden behind a big one, its sides project on x = 83.75 and 117.25
+	engine.Add(square(t, 0, 1, green), square(t, 3, 1, red))
+	render := func(w Wireframe) *image.RGBA {
+		img, err := engine.Render(context.Background(), 201, 1, WithWireframe(w))
+		require.NoError(t, err)
+		return img
+	}
+	column := func(img *image.RGBA) int {
+		n := 0
+		for y := 90; y < 110; y++ {
+			if img.RGBAAt(84, y).A > 0 {
+				n++
+			}
+		}
+		return n
+	}
+
+	require.Zero(t, column(render(Wireframe{})))
+	require.Equal(t, 20, column(render(Wireframe{Hidden: ShowHiddenEdges})))
+	dashed := column(render(Wireframe{Hidden: DashHiddenEdges}))
+	require.Greater(t, dashed, 5)
+	require.Less(t, dashed, 15)
+
+	// surfaces are painted without the edge colors, under the lines
+	img := render(Wireframe{Surfaces: true, Color: color.White})
+	require.Equal(t, green, img.RGBAAt(100, 100))
+	require.Equal(t, green, img.RGBAAt(80, 100))
+	require.Greater(t, img.RGBAAt(67, 100).R, uint8(200))
+	require.Zero(t, img.RGBAAt(50, 100).A)
+}